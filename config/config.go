@@ -0,0 +1,492 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ConfigFile 默认配置文件路径，可通过环境变量 MUSIC_API_CONFIG 指定
+const ConfigFile = "config.json"
+
+// API Key 角色：admin 可读写系统配置，client 为只读客户端；
+// user 为本地用户令牌的角色，不能通过 API Key 配置
+const (
+	RoleAdmin  = "admin"
+	RoleClient = "client"
+	RoleUser   = "user"
+)
+
+type APIKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Role string `json:"role"`
+}
+
+type AuthConfig struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// RateLimitRule 令牌桶限流规则，Rate 为每秒请求数，<=0 表示不限流
+type RateLimitRule struct {
+	Rate        float64 `json:"rate"`
+	Burst       int     `json:"burst"`
+	Concurrency int     `json:"concurrency"` // 单个客户端同时进行的请求上限，<=0 表示不限
+}
+
+// OutboundLimitConfig 服务访问各音乐平台的全局出站限流
+type OutboundLimitConfig struct {
+	Default   RateLimitRule            `json:"default"`
+	Sources   map[string]RateLimitRule `json:"sources"`
+	MaxWaitMs int                      `json:"max_wait_ms"` // 排队等待令牌的最长时间，超时按上游限流处理
+}
+
+// RateLimitConfig 按 API Key 或客户端 IP 统计的入站限流，以及按平台的出站限流
+type RateLimitConfig struct {
+	Search   RateLimitRule       `json:"search"`
+	Stream   RateLimitRule       `json:"stream"`
+	QRLogin  RateLimitRule       `json:"qr_login"`
+	Login    RateLimitRule       `json:"login"` // 用户注册与登录
+	Outbound OutboundLimitConfig `json:"outbound"`
+}
+
+// CORSPolicy 跨域策略。AllowedOrigins 支持 "*" 与 "https://*.example.com" 形式的子域名通配，
+// AllowedHeaders 支持 "X-Music-Cookie-*" 形式的前缀通配；允许携带凭据时回显请求的 Origin，且不能与 "*" 同时使用。
+type CORSPolicy struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"` // 预检结果缓存秒数
+}
+
+// validate 拒绝任意来源与携带凭据的组合，否则任何网站都能以用户身份调用接口
+func (p CORSPolicy) validate(name string) error {
+	if p.AllowCredentials && slices.Contains(p.AllowedOrigins, "*") {
+		return fmt.Errorf("cors.%s: allow_credentials cannot be used with allowed_origins \"*\"", name)
+	}
+	return nil
+}
+
+// CORSConfig 分别配置标准 API 与兼容路由组的跨域策略
+type CORSConfig struct {
+	API    CORSPolicy `json:"api"`
+	Compat CORSPolicy `json:"compat"`
+}
+
+// Cookie 账号池的选取策略
+const (
+	CookieSelectRoundRobin = "round_robin"
+	CookieSelectLRU        = "lru"
+)
+
+// CookieStoreConfig Cookie 存储与账号池设置。加密密钥两项均为空时以明文存储。
+type CookieStoreConfig struct {
+	EncryptionKey      string `json:"encryption_key"`
+	EncryptionKeyFile  string `json:"encryption_key_file"`
+	Selection          string `json:"selection"`            // round_robin 或 lru
+	QuarantineAfter    int    `json:"quarantine_after"`     // 连续鉴权失败多少次后隔离账号
+	QuarantineMinutes  int    `json:"quarantine_minutes"`   // 隔离时长
+	HealthCheckMinutes int    `json:"health_check_minutes"` // 后台校验 Cookie 的间隔，0 为默认值，负数关闭
+}
+
+// Key 返回加密口令，未配置时返回 nil；实际密钥由存储层以 scrypt 加随机盐派生
+func (c CookieStoreConfig) Key() ([]byte, error) {
+	secret := c.EncryptionKey
+	if secret == "" && c.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(c.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read cookie key file: %w", err)
+		}
+		secret = string(data)
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return nil, nil
+	}
+	return []byte(secret), nil
+}
+
+// QRLoginConfig 扫码登录会话设置
+type QRLoginConfig struct {
+	SessionTTLSeconds   int `json:"session_ttl_seconds"`   // 会话有效期，超过后拒绝轮询
+	PollIntervalSeconds int `json:"poll_interval_seconds"` // 推送状态时服务端轮询平台的间隔
+}
+
+// UsersConfig 本地用户设置
+type UsersConfig struct {
+	OpenRegistration bool `json:"open_registration"` // 为 false 时只有管理员可以创建用户
+}
+
+// CircuitBreakerConfig 按平台的熔断设置：连续失败达到阈值后在冷却期内直接跳过该平台，
+// 冷却结束后只放行一个试探请求，成功则恢复，失败则重新熔断
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold"` // 连续失败次数阈值，负数关闭熔断
+	CooldownSeconds  int `json:"cooldown_seconds"`
+}
+
+// RetryPolicy 上游调用的重试策略，退避时间按 base_delay_ms 指数增长并加入随机抖动
+type RetryPolicy struct {
+	MaxAttempts int `json:"max_attempts"` // 含首次调用的总次数，1 表示不重试
+	BaseDelayMs int `json:"base_delay_ms"`
+	MaxDelayMs  int `json:"max_delay_ms"`
+}
+
+// RetryConfig 按能力配置的重试策略，未单独配置的能力使用 default
+type RetryConfig struct {
+	Default      RetryPolicy            `json:"default"`
+	Capabilities map[string]RetryPolicy `json:"capabilities"` // 键为能力名，如 search、playlist_detail
+}
+
+// Policy 返回能力实际使用的重试策略，未填写的字段取 default
+func (r RetryConfig) Policy(capability string) RetryPolicy {
+	p, ok := r.Capabilities[capability]
+	if !ok {
+		return r.Default
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = r.Default.MaxAttempts
+	}
+	if p.BaseDelayMs <= 0 {
+		p.BaseDelayMs = r.Default.BaseDelayMs
+	}
+	if p.MaxDelayMs <= 0 {
+		p.MaxDelayMs = r.Default.MaxDelayMs
+	}
+	return p
+}
+
+// SourceProbeConfig 单个平台的合成探测设置
+type SourceProbeConfig struct {
+	Keyword  string `json:"keyword"`  // 覆盖默认的搜索关键词
+	Disabled bool   `json:"disabled"` // 不探测该平台
+}
+
+// HealthConfig 平台合成探测设置：依次执行搜索、获取下载链接与 Range 探测
+type HealthConfig struct {
+	ProbeMinutes int                          `json:"probe_minutes"` // 后台探测间隔，负数关闭后台探测，仍可按需触发
+	Keyword      string                       `json:"keyword"`       // 默认搜索关键词
+	Sources      map[string]SourceProbeConfig `json:"sources"`
+}
+
+// Probe 返回平台实际使用的探测设置
+func (h HealthConfig) Probe(source string) SourceProbeConfig {
+	p := h.Sources[source]
+	if strings.TrimSpace(p.Keyword) == "" {
+		p.Keyword = h.Keyword
+	}
+	return p
+}
+
+// HTTPClientConfig 串流、可播放探测等直接访问音频直链的请求共用的 HTTP 客户端设置，单位均为秒
+type HTTPClientConfig struct {
+	DialTimeoutSeconds           int `json:"dial_timeout_seconds"`
+	TLSHandshakeTimeoutSeconds   int `json:"tls_handshake_timeout_seconds"`
+	ResponseHeaderTimeoutSeconds int `json:"response_header_timeout_seconds"` // 等待上游响应头的最长时间
+	IdleConnTimeoutSeconds       int `json:"idle_conn_timeout_seconds"`       // 空闲连接在池中保留的时间
+	MaxIdleConnsPerHost          int `json:"max_idle_conns_per_host"`
+	StreamIdleSeconds            int `json:"stream_idle_seconds"`   // 串流时上游超过该时间没有新数据则中断，负数不限
+	ProbeTimeoutSeconds          int `json:"probe_timeout_seconds"` // Range 探测、出口测试与 Cookie 校验的整体超时
+}
+
+// ProxyDirect 在 proxy.sources 中表示该平台不走默认代理
+const ProxyDirect = "direct"
+
+// ProxyConfig 出站代理，支持 http://、https://、socks5:// 与 socks5h:// 地址
+type ProxyConfig struct {
+	Default string              `json:"default"` // 默认代理，为空直连
+	Sources map[string]string   `json:"sources"` // 按平台覆盖，值为 direct 表示直连
+	Hosts   map[string][]string `json:"hosts"`   // 为平台追加域名，平台接口按域名识别所属平台后选择代理
+	// RouteLibraryTraffic 替换进程级 http.DefaultTransport，让平台库发起的接口调用也走代理。
+	// 未填写时配置了任一代理即开启，填 false 可显式关闭
+	RouteLibraryTraffic *bool `json:"route_library_traffic"`
+}
+
+// RoutesLibraryTraffic 返回是否让平台库的接口调用也按平台走代理
+func (p ProxyConfig) RoutesLibraryTraffic() bool {
+	if p.RouteLibraryTraffic != nil {
+		return *p.RouteLibraryTraffic
+	}
+	proxies := []string{p.Default}
+	for _, proxy := range p.Sources {
+		proxies = append(proxies, proxy)
+	}
+	for _, proxy := range proxies {
+		if proxy = strings.TrimSpace(proxy); proxy != "" && proxy != ProxyDirect {
+			return true
+		}
+	}
+	return false
+}
+
+// For 返回平台实际使用的代理地址，直连时返回空串
+func (p ProxyConfig) For(source string) string {
+	proxy, ok := p.Sources[source]
+	if !ok {
+		proxy = p.Default
+	}
+	proxy = strings.TrimSpace(proxy)
+	if proxy == ProxyDirect {
+		return ""
+	}
+	return proxy
+}
+
+func (p ProxyConfig) validate() error {
+	proxies := []string{p.Default}
+	for _, proxy := range p.Sources {
+		proxies = append(proxies, proxy)
+	}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" || proxy == ProxyDirect {
+			continue
+		}
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %q: %w", proxy, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("invalid proxy %q: unsupported scheme %q", proxy, u.Scheme)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid proxy %q: missing host", proxy)
+		}
+	}
+	return nil
+}
+
+// HeaderProfile 访问平台音频直链与封面时附带的请求头，未填写的字段使用平台内置的默认值
+type HeaderProfile struct {
+	UserAgent string            `json:"user_agent"`
+	Referer   string            `json:"referer"`
+	Headers   map[string]string `json:"headers"` // 其他请求头，值为空表示不发送该请求头，如 {"Referer": ""}
+}
+
+// 日志输出格式
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// LogConfig 日志设置，level 为 debug 时输出每次上游调用的耗时与结果
+type LogConfig struct {
+	Level  string `json:"level"`  // debug、info、warn、error
+	Format string `json:"format"` // json 或 text
+}
+
+type Config struct {
+	Auth      AuthConfig               `json:"auth"`
+	RateLimit RateLimitConfig          `json:"rate_limit"`
+	CORS      CORSConfig               `json:"cors"`
+	Cookies   CookieStoreConfig        `json:"cookies"`
+	Users     UsersConfig              `json:"users"`
+	QRLogin   QRLoginConfig            `json:"qr_login"`
+	Log       LogConfig                `json:"log"`
+	Health    HealthConfig             `json:"health"`
+	Circuit   CircuitBreakerConfig     `json:"circuit_breaker"`
+	Retry     RetryConfig              `json:"retry"`
+	HTTP      HTTPClientConfig         `json:"http_client"`
+	Proxy     ProxyConfig              `json:"proxy"`
+	Headers   map[string]HeaderProfile `json:"source_headers"` // 按平台覆盖请求头
+}
+
+// C 当前生效的配置，启动时由 Load 初始化
+var C = Default()
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Origin", "X-Requested-With", "Content-Type", "Accept", "Authorization", "X-API-Key", "Range", "X-Music-Cookie-*", "X-Request-ID"}
+	defaultCORSExposed = []string{"Content-Length", "Content-Range", "Content-Disposition", "Cache-Control", "Content-Language", "Content-Type", "X-Lyric-Source", "X-Lyric-Fallback", "Retry-After", "X-Request-ID", "X-Source-Status"}
+)
+
+// Default 返回未提供配置文件时使用的默认配置
+func Default() *Config {
+	return &Config{
+		QRLogin: QRLoginConfig{SessionTTLSeconds: 300, PollIntervalSeconds: 2},
+		Log:     LogConfig{Level: "info", Format: LogFormatJSON},
+		Health:  HealthConfig{ProbeMinutes: 15, Keyword: "周杰伦"},
+		Circuit: CircuitBreakerConfig{FailureThreshold: 5, CooldownSeconds: 30},
+		Retry:   RetryConfig{Default: RetryPolicy{MaxAttempts: 3, BaseDelayMs: 200, MaxDelayMs: 2000}},
+		HTTP: HTTPClientConfig{
+			DialTimeoutSeconds:           10,
+			TLSHandshakeTimeoutSeconds:   10,
+			ResponseHeaderTimeoutSeconds: 15,
+			IdleConnTimeoutSeconds:       90,
+			MaxIdleConnsPerHost:          16,
+			StreamIdleSeconds:            30,
+			ProbeTimeoutSeconds:          5,
+		},
+		Cookies: CookieStoreConfig{
+			Selection:          CookieSelectRoundRobin,
+			QuarantineAfter:    3,
+			QuarantineMinutes:  30,
+			HealthCheckMinutes: 360,
+		},
+		CORS: CORSConfig{
+			API: CORSPolicy{AllowedOrigins: []string{"*"}, MaxAge: 600},
+			// 兼容组沿用旧版前端的宽松策略：任意来源，不携带凭据
+			Compat: CORSPolicy{AllowedOrigins: []string{"*"}, MaxAge: 600},
+		},
+		RateLimit: RateLimitConfig{
+			Search:  RateLimitRule{Rate: 2, Burst: 10, Concurrency: 4},
+			Stream:  RateLimitRule{Rate: 5, Burst: 20, Concurrency: 8},
+			QRLogin: RateLimitRule{Rate: 1, Burst: 10, Concurrency: 2},
+			Login:   RateLimitRule{Rate: 0.2, Burst: 5, Concurrency: 1},
+			Outbound: OutboundLimitConfig{
+				Default:   RateLimitRule{Rate: 10, Burst: 20},
+				MaxWaitMs: 3000,
+			},
+		},
+	}
+}
+
+func Path() string {
+	if p := strings.TrimSpace(os.Getenv("MUSIC_API_CONFIG")); p != "" {
+		return p
+	}
+	return ConfigFile
+}
+
+// Load 读取配置文件并应用环境变量覆盖，文件不存在时使用默认配置
+func Load() error {
+	cfg := Default()
+	data, err := os.ReadFile(Path())
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("parse %s: %w", Path(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	applyEnv(cfg)
+	normalize(cfg)
+	if err := cfg.Proxy.validate(); err != nil {
+		return err
+	}
+	if err := cfg.CORS.API.validate("api"); err != nil {
+		return err
+	}
+	if err := cfg.CORS.Compat.validate("compat"); err != nil {
+		return err
+	}
+	C = cfg
+	return nil
+}
+
+// applyEnv 支持通过环境变量追加 API Key 与 Cookie 加密密钥，便于容器部署
+func applyEnv(cfg *Config) {
+	if key := strings.TrimSpace(os.Getenv("MUSIC_API_COOKIE_KEY")); key != "" {
+		cfg.Cookies.EncryptionKey = key
+	}
+	if file := strings.TrimSpace(os.Getenv("MUSIC_API_COOKIE_KEY_FILE")); file != "" {
+		cfg.Cookies.EncryptionKeyFile = file
+	}
+	if level := strings.TrimSpace(os.Getenv("MUSIC_API_LOG_LEVEL")); level != "" {
+		cfg.Log.Level = level
+	}
+	if key := strings.TrimSpace(os.Getenv("MUSIC_API_ADMIN_KEY")); key != "" {
+		cfg.Auth.Keys = append(cfg.Auth.Keys, APIKey{Name: "env-admin", Key: key, Role: RoleAdmin})
+	}
+	for i, key := range strings.Split(os.Getenv("MUSIC_API_CLIENT_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.Auth.Keys = append(cfg.Auth.Keys, APIKey{Name: fmt.Sprintf("env-client-%d", i+1), Key: key, Role: RoleClient})
+		}
+	}
+}
+
+func normalize(cfg *Config) {
+	if cfg.Circuit.FailureThreshold == 0 {
+		cfg.Circuit.FailureThreshold = 5
+	}
+	if cfg.Circuit.CooldownSeconds <= 0 {
+		cfg.Circuit.CooldownSeconds = 30
+	}
+	if cfg.Retry.Default.MaxAttempts <= 0 {
+		cfg.Retry.Default.MaxAttempts = 3
+	}
+	if cfg.Retry.Default.BaseDelayMs <= 0 {
+		cfg.Retry.Default.BaseDelayMs = 200
+	}
+	if cfg.Retry.Default.MaxDelayMs < cfg.Retry.Default.BaseDelayMs {
+		cfg.Retry.Default.MaxDelayMs = max(2000, cfg.Retry.Default.BaseDelayMs)
+	}
+	if cfg.HTTP.DialTimeoutSeconds <= 0 {
+		cfg.HTTP.DialTimeoutSeconds = 10
+	}
+	if cfg.HTTP.TLSHandshakeTimeoutSeconds <= 0 {
+		cfg.HTTP.TLSHandshakeTimeoutSeconds = 10
+	}
+	if cfg.HTTP.ResponseHeaderTimeoutSeconds <= 0 {
+		cfg.HTTP.ResponseHeaderTimeoutSeconds = 15
+	}
+	if cfg.HTTP.IdleConnTimeoutSeconds <= 0 {
+		cfg.HTTP.IdleConnTimeoutSeconds = 90
+	}
+	if cfg.HTTP.MaxIdleConnsPerHost <= 0 {
+		cfg.HTTP.MaxIdleConnsPerHost = 16
+	}
+	if cfg.HTTP.ProbeTimeoutSeconds <= 0 {
+		cfg.HTTP.ProbeTimeoutSeconds = 5
+	}
+	if cfg.HTTP.StreamIdleSeconds == 0 {
+		cfg.HTTP.StreamIdleSeconds = 30
+	}
+	if cfg.Health.ProbeMinutes == 0 {
+		cfg.Health.ProbeMinutes = 15
+	}
+	if strings.TrimSpace(cfg.Health.Keyword) == "" {
+		cfg.Health.Keyword = "周杰伦"
+	}
+	if cfg.Log.Format != LogFormatText {
+		cfg.Log.Format = LogFormatJSON
+	}
+	if cfg.Cookies.Selection != CookieSelectLRU {
+		cfg.Cookies.Selection = CookieSelectRoundRobin
+	}
+	if cfg.Cookies.QuarantineAfter <= 0 {
+		cfg.Cookies.QuarantineAfter = 3
+	}
+	if cfg.Cookies.QuarantineMinutes <= 0 {
+		cfg.Cookies.QuarantineMinutes = 30
+	}
+	if cfg.QRLogin.SessionTTLSeconds <= 0 {
+		cfg.QRLogin.SessionTTLSeconds = 300
+	}
+	if cfg.QRLogin.PollIntervalSeconds <= 0 {
+		cfg.QRLogin.PollIntervalSeconds = 2
+	}
+	if cfg.Cookies.HealthCheckMinutes == 0 {
+		cfg.Cookies.HealthCheckMinutes = 360
+	}
+	for _, policy := range []*CORSPolicy{&cfg.CORS.API, &cfg.CORS.Compat} {
+		if len(policy.AllowedMethods) == 0 {
+			policy.AllowedMethods = defaultCORSMethods
+		}
+		if len(policy.AllowedHeaders) == 0 {
+			policy.AllowedHeaders = defaultCORSHeaders
+		}
+		if len(policy.ExposedHeaders) == 0 {
+			policy.ExposedHeaders = defaultCORSExposed
+		}
+	}
+
+	keys := cfg.Auth.Keys[:0]
+	for _, k := range cfg.Auth.Keys {
+		k.Key = strings.TrimSpace(k.Key)
+		if k.Key == "" {
+			continue
+		}
+		if k.Role != RoleAdmin {
+			k.Role = RoleClient
+		}
+		keys = append(keys, k)
+	}
+	cfg.Auth.Keys = keys
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsWildcardWithCredentials(t *testing.T) {
+	old := C
+	t.Cleanup(func() { C = old })
+
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"api wildcard with credentials", `{"cors":{"api":{"allowed_origins":["*"],"allow_credentials":true}}}`, "cors.api"},
+		{"compat wildcard with credentials", `{"cors":{"compat":{"allowed_origins":["https://a.example.com","*"],"allow_credentials":true}}}`, "cors.compat"},
+		{"explicit origin with credentials", `{"cors":{"api":{"allowed_origins":["https://a.example.com"],"allow_credentials":true}}}`, ""},
+		{"wildcard without credentials", `{"cors":{"api":{"allowed_origins":["*"]}}}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("MUSIC_API_CONFIG", path)
+			err := Load()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("Load() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Load() = %v, want error mentioning %s", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestProxyRoutesLibraryTraffic(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name  string
+		proxy ProxyConfig
+		want  bool
+	}{
+		{"no proxy", ProxyConfig{}, false},
+		{"only direct overrides", ProxyConfig{Sources: map[string]string{"qq": ProxyDirect}}, false},
+		{"default proxy", ProxyConfig{Default: "socks5://127.0.0.1:1080"}, true},
+		{"per-source proxy", ProxyConfig{Sources: map[string]string{"joox": "socks5://127.0.0.1:1080"}}, true},
+		{"explicitly off", ProxyConfig{Sources: map[string]string{"joox": "socks5://127.0.0.1:1080"}, RouteLibraryTraffic: &off}, false},
+		{"explicitly on", ProxyConfig{RouteLibraryTraffic: &on}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.proxy.RoutesLibraryTraffic(); got != tt.want {
+				t.Errorf("RoutesLibraryTraffic() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -66,6 +66,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
+                    },
+                    "404": {
+                        "description": "专辑不存在",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
                     }
                 }
             }
@@ -113,6 +125,18 @@ const docTemplate = `{
                         "schema": {
                             "type": "file"
                         }
+                    },
+                    "400": {
+                        "description": "缺少 url",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "封面拉取失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
                     }
                 }
             }
@@ -161,13 +185,31 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
+                    },
+                    "400": {
+                        "description": "音源不支持",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "找不到音频URL",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
                     }
                 }
             }
         },
         "/api/v1/music/lyric": {
             "get": {
-                "description": "抓取对应歌曲的完整 LRC 歌词文本，以 JSON 格式返回。",
+                "description": "抓取对应歌曲的完整 LRC 歌词文本，以 JSON 格式返回。原平台无歌词或不支持歌词时，会按歌名、歌手和时长在其它平台查找同一首歌的歌词，并在 ` + "`" + `source` + "`" + ` 中返回实际提供歌词的平台。",
                 "produces": [
                     "application/json"
                 ],
@@ -193,17 +235,78 @@ const docTemplate = `{
                         "name": "source",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "香水有毒",
+                        "example": "香水有毒",
+                        "description": "歌曲名称 (跨平台回退查找时必填)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "胡杨林",
+                        "example": "胡杨林",
+                        "description": "歌手名称",
+                        "name": "artist",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 290,
+                        "description": "歌曲时长(秒)，提高回退匹配准确度",
+                        "name": "duration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "原平台无歌词时是否跨平台回退查找",
+                        "name": "fallback",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": -500,
+                        "description": "歌词时间偏移(毫秒)，正数表示歌词延后；留空则使用已保存的该曲校正值",
+                        "name": "offset_ms",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "包含 lyric 字符串属性的数据对象",
+                        "description": "包含 lyric、source、fallback、offset_ms 属性的数据对象",
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
                     },
                     "400": {
-                        "description": "对应平台未实现歌词抓取",
+                        "description": "对应平台未实现歌词抓取或 offset_ms 非法",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "需要登录 Cookie 或 Cookie 已失效",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "各平台均无歌词",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "上游平台限流",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
@@ -213,14 +316,14 @@ const docTemplate = `{
         },
         "/api/v1/music/lyric/file": {
             "get": {
-                "description": "作为附件直接下载 ` + "`" + `.lrc` + "`" + ` 后缀的歌词文件到本地。",
+                "description": "作为附件下载歌词文件，支持将 LRC 转换为 SRT、WebVTT、ASS (逐字歌词生成卡拉 OK 标签) 与 TTML 格式。",
                 "produces": [
                     "application/octet-stream"
                 ],
                 "tags": [
                     "Music"
                 ],
-                "summary": "下载 LRC 歌词文件",
+                "summary": "下载歌词文件",
                 "parameters": [
                     {
                         "type": "string",
@@ -255,14 +358,168 @@ const docTemplate = `{
                         "description": "歌手名称 (生成保存文件名)",
                         "name": "artist",
                         "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 290,
+                        "description": "歌曲时长(秒)，用于推算最后一行歌词的结束时间",
+                        "name": "duration",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "lrc",
+                            "srt",
+                            "vtt",
+                            "ass",
+                            "ttml"
+                        ],
+                        "type": "string",
+                        "default": "lrc",
+                        "description": "导出格式",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "原平台无歌词时是否跨平台回退查找",
+                        "name": "fallback",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": -500,
+                        "description": "歌词时间偏移(毫秒)，正数表示歌词延后；留空则使用已保存的该曲校正值",
+                        "name": "offset_ms",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "纯文本文件流",
+                        "description": "歌词文件流",
                         "schema": {
                             "type": "file"
                         }
+                    },
+                    "400": {
+                        "description": "不支持的导出格式、平台或 offset_ms 非法",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "各平台均无歌词",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/music/lyric/offset": {
+            "get": {
+                "description": "返回指定歌曲已保存的歌词时间偏移(毫秒)，未保存时为 0。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Music"
+                ],
+                "summary": "获取已保存的歌词偏移校正",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "240479",
+                        "example": "240479",
+                        "description": "音乐 ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "netease",
+                        "example": "netease",
+                        "description": "平台",
+                        "name": "source",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "包含 offset_ms 的数据对象",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "参数缺失",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "保存指定歌曲的歌词时间偏移(毫秒，正数表示歌词延后)，之后未显式传入 offset_ms 的歌词请求都会自动应用。传 0 表示清除。需要 client 角色。",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Music"
+                ],
+                "summary": "保存歌词偏移校正",
+                "parameters": [
+                    {
+                        "description": "歌词偏移校正",
+                        "name": "offset",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.lyricOffsetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "操作成功",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "参数缺失、平台不支持、ID 过长或偏移超出范围",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "保存失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
                     }
                 }
             }
@@ -323,8 +580,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.Response"
                         }
                     },
-                    "500": {
-                        "description": "解析过程出现错误",
+                    "401": {
+                        "description": "链接解析需要登录 Cookie",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
@@ -396,21 +659,45 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "参数缺失或非法",
+                        "description": "参数缺失或音源不支持",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "需要登录 Cookie 或 Cookie 已失效",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
                         }
                     },
                     "404": {
                         "description": "找不到音频URL",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "上游平台限流",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
                         }
                     },
                     "500": {
                         "description": "音频解密失败",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "504": {
+                        "description": "上游平台超时",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
                         }
                     }
                 }
@@ -535,7 +822,19 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.Response"
                         }
                     },
-                    "500": {
+                    "401": {
+                        "description": "需要登录 Cookie",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "无可用音频链接",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
                         "description": "链接抓取失败",
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
@@ -638,6 +937,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
                     }
                 }
             }
@@ -684,6 +989,18 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
+                    },
+                    "404": {
+                        "description": "歌单不存在",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
                     }
                 }
             }
@@ -772,13 +1089,30 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/handler.Response"
                         }
+                    },
+                    "401": {
+                        "description": "需要登录 Cookie 或 Cookie 已失效",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
                     }
                 }
             }
         },
         "/api/v1/system/cookies": {
             "get": {
-                "description": "读取并在 JSON 格式下返回当前系统已配置的各平台 Cookies。",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "读取并在 JSON 格式下返回当前系统已配置的各平台 Cookies。非管理员 API Key 只能看到脱敏后的 Cookie 值。",
                 "produces": [
                     "application/json"
                 ],
@@ -795,10 +1129,21 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
-                    }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
                 }
             },
             "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "description": "接收 JSON 格式的平台 cookie 键值对，覆盖并保存到系统，实时生效。",
                 "consumes": [
                     "application/json"
@@ -807,191 +1152,1594 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "System"
+                    "System"
+                ],
+                "summary": "设置系统 Cookies",
+                "parameters": [
+                    {
+                        "description": "平台Cookies映射示例：{\\",
+                        "name": "cookies",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "操作成功",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "参数解析失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "保存失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/cookies/accounts": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回每个平台配置的全部账号及其使用时间、连续鉴权失败次数与隔离状态。非管理员 API Key 只能看到脱敏后的 Cookie 值。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "列出各平台的 Cookie 账号池",
+                "responses": {
+                    "200": {
+                        "description": "按平台分组的账号列表",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/cookies/accounts/{source}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "为指定平台追加一个 Cookie 账号，请求按配置的轮询或最近最少使用策略在账号间分配。id 留空时自动生成。",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "向平台账号池添加账号",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "netease",
+                        "description": "音乐平台",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "账号信息",
+                        "name": "account",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.cookieAccountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "新增的账号",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "参数错误或账号 ID 已存在",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "保存失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/cookies/accounts/{source}/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "修改账号备注",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "netease",
+                        "description": "音乐平台",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "账号 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "账号备注",
+                        "name": "label",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.cookieAccountLabelRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "修改后的账号",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "参数错误",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "账号不存在",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "从平台账号池删除账号",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "netease",
+                        "description": "音乐平台",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "账号 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "操作成功",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "账号不存在",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/cookies/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回后台定期校验的结果：最近校验时间、最近一次校验通过的时间、从 Cookie 字段解析出的过期时间与隔离状态。管理员可传 refresh=true 立即重新校验。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "查看各账号 Cookie 的校验状态",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "立即重新校验(仅管理员)",
+                        "name": "refresh",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "各账号的校验状态",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/egress": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回各平台请求实际使用的出口：direct(直连)或 proxy(经 proxy 配置的代理，密码已隐去)，以及用于识别平台请求的域名。传入 url 时返回访问该地址会选择的出口。管理员可传 test=true 经对应出口实际发送一次 HEAD 请求，收到任意 HTTP 响应即视为连通。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "查看各平台的出站路径",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "joox",
+                        "description": "只返回指定平台，多个用逗号分隔",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "https://api.joox.com/",
+                        "description": "查询访问该地址时选择的出口",
+                        "name": "url",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "经出口发送测试请求(仅管理员)",
+                        "name": "test",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "各平台出站路径",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "url 格式错误",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/health": {
+            "get": {
+                "description": "服务进程存活时返回 ok 与运行时长，不访问任何上游平台，无需 API Key，可用于负载均衡与容器健康检查。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "存活检查",
+                "responses": {
+                    "200": {
+                        "description": "服务存活",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/health/sources": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回各平台最近一次合成探测的结果。探测依次执行：用配置的关键词搜索、对第一首结果获取下载链接、对链接发起 Range 请求，分别对应 search、download_url、playable 阶段，可据此区分\"搜索失效\"与\"链接返回 403\"。状态为 ok、degraded(搜索可用但链接或播放失败)、down(搜索失败)、pending(尚未探测)或 disabled。后台按 health.probe_minutes 定期探测，管理员可传 refresh=true 立即重新探测。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "查看各平台合成探测结果",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "kuwo",
+                        "description": "只返回指定平台，多个用逗号分隔",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "立即重新探测(仅管理员)",
+                        "name": "refresh",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "各平台探测结果",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/qr_login/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "列出服务端记录的扫码登录会话(平台、key、状态、创建者、创建与过期时间)，便于管理员查看等待中的登录。已过期的会话保留 10 分钟。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "查看扫码登录会话",
+                "responses": {
+                    "200": {
+                        "description": "扫码登录会话列表",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "需要管理员权限",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/qr_login/sources": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回当前 API 支持创建二维码登录会话的平台列表。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "获取支持扫码登录的平台",
+                "responses": {
+                    "200": {
+                        "description": "支持扫码登录的平台",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/qr_login/{source}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "使用创建扫码登录会话返回的 key 轮询登录状态，只接受本服务创建且未过期的会话，且只有创建者与管理员可以轮询。成功时：会话由用户令牌创建则写入该用户的 Cookie 保险箱，由管理员创建则写入 cookies.json，其它 API Key 只返回 Cookie 不保存。保存失败时 extra 中 cookie_saved 为 false、cookie_error 为原因，再次轮询会重新保存。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "轮询扫码登录状态",
+                "parameters": [
+                    {
+                        "enum": [
+                            "netease",
+                            "qq",
+                            "qq_wx",
+                            "kugou",
+                            "bilibili"
+                        ],
+                        "type": "string",
+                        "example": "qq_wx",
+                        "description": "扫码登录平台",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "扫码登录 key",
+                        "name": "key",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "扫码登录状态",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "平台不支持扫码登录",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "未知的扫码登录 key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "410": {
+                        "description": "扫码登录会话已过期",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "为指定平台创建扫码登录会话，返回二维码 URL、二维码图片地址或平台登录 key。会话在 qr_login.session_ttl_seconds 后过期，过期时间见 extra.expires_at。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "创建扫码登录会话",
+                "parameters": [
+                    {
+                        "enum": [
+                            "netease",
+                            "qq",
+                            "qq_wx",
+                            "kugou",
+                            "bilibili"
+                        ],
+                        "type": "string",
+                        "example": "qq_wx",
+                        "description": "扫码登录平台",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "扫码登录会话",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "平台不支持扫码登录",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "502": {
+                        "description": "上游平台请求失败",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/qr_login/{source}/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "以 Server-Sent Events 推送扫码登录会话的状态变化，替代客户端反复轮询。服务端按 qr_login.poll_interval_seconds 轮询平台，同一会话的多个订阅者共享一个轮询；每次状态变化(waiting、scanned、confirmed、success、expired 等)推送一条 status 事件，首条为当前状态。登录成功时 Cookie 的保存规则与轮询接口相同，保存失败时事件的 message 注明原因且不结束推送，之后的轮询会重新保存；会话结束(terminal 为 true)后连接关闭。轮询平台失败时推送 state 为 error 的事件并继续轮询。只有创建者与管理员可以订阅，浏览器 EventSource 可通过 api_key 查询参数鉴权。",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "订阅扫码登录状态(SSE)",
+                "parameters": [
+                    {
+                        "enum": [
+                            "netease",
+                            "qq",
+                            "qq_wx",
+                            "kugou",
+                            "bilibili"
+                        ],
+                        "type": "string",
+                        "example": "netease",
+                        "description": "扫码登录平台",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "扫码登录 key",
+                        "name": "key",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "status 事件，data 为状态 JSON",
+                        "schema": {
+                            "$ref": "#/definitions/service.QREvent"
+                        }
+                    },
+                    "400": {
+                        "description": "缺少 key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "未知的扫码登录 key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "410": {
+                        "description": "扫码登录会话已过期",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/system/qr_login/{source}/image": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "在服务端将扫码登录会话的登录链接渲染为二维码图片，可直接用于 \u003cimg\u003e 标签(此时可通过 api_key 查询参数鉴权)。只接受本服务创建且未过期的会话。",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "获取扫码登录二维码图片",
+                "parameters": [
+                    {
+                        "enum": [
+                            "netease",
+                            "qq",
+                            "qq_wx",
+                            "kugou",
+                            "bilibili"
+                        ],
+                        "type": "string",
+                        "example": "netease",
+                        "description": "扫码登录平台",
+                        "name": "source",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "扫码登录 key",
+                        "name": "key",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "png",
+                            "svg"
+                        ],
+                        "type": "string",
+                        "default": "png",
+                        "description": "图片格式",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 256,
+                        "description": "图片边长(像素)，范围 64-1024",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "二维码图片",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "缺少 key 或格式不支持",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "未知的扫码登录 key，或会话没有可编码的登录链接",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "410": {
+                        "description": "扫码登录会话已过期",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/login": {
+            "post": {
+                "description": "校验用户名与密码后签发新的 API 令牌。令牌只在此时返回一次，之后通过 X-API-Key 或 Authorization: Bearer 携带。",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "登录并获取 API 令牌",
+                "parameters": [
+                    {
+                        "description": "用户名与密码",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.userCredentials"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "API 令牌",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "参数错误",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "用户名或密码错误",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回当前用户的令牌列表(不含令牌本身)与脱敏后的个人 Cookie。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "获取当前用户信息",
+                "responses": {
+                    "200": {
+                        "description": "用户信息",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "需要用户令牌",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/cookies": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "返回脱敏后的个人 Cookie。以用户令牌调用其它接口时，这些 Cookie 优先于服务端账号池使用。",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "查看当前用户的个人 Cookie",
+                "responses": {
+                    "200": {
+                        "description": "平台 Cookie 键值对(脱敏)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "需要用户令牌",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "接收平台 Cookie 键值对写入个人保险箱，值为空字符串表示删除该平台。",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "更新当前用户的个人 Cookie",
+                "parameters": [
+                    {
+                        "description": "平台Cookies映射示例：{\\",
+                        "name": "cookies",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "操作成功",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "参数解析失败或平台不支持",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "需要用户令牌",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/tokens": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "为当前用户签发新的 API 令牌",
+                "parameters": [
+                    {
+                        "description": "令牌备注",
+                        "name": "token",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.userTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "API 令牌",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "需要用户令牌",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/me/tokens/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "吊销当前用户的 API 令牌",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "令牌 ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "操作成功",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "需要用户令牌",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "令牌不存在",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/register": {
+            "post": {
+                "description": "创建用户账号，密码使用 bcrypt 哈希保存。未开启 users.open_registration 时只有管理员可以创建用户。",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "注册本地用户",
+                "parameters": [
+                    {
+                        "description": "用户名与密码",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.userCredentials"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "新用户信息",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "用户名或密码不合法、用户名已存在",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "未开放注册",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "以 Prometheus 文本格式导出指标：各路由的请求数与耗时，各平台各能力的上游调用数(按结果与错误类型区分)与耗时，串流字节数，汽水音乐解密耗时，以及 Go 运行时指标。配置了 API Key 时需要 client 角色，抓取时可使用 Bearer 鉴权。",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "Prometheus 指标",
+                "responses": {
+                    "200": {
+                        "description": "Prometheus 文本格式指标",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "缺少或无效的 API Key",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/music/lyric": {
+            "get": {
+                "description": "直接返回 ` + "`" + `text/plain` + "`" + ` 格式的纯歌词内容。原平台无歌词时跨平台回退查找，实际来源写入 ` + "`" + `X-Lyric-Source` + "`" + ` 响应头。若仍拉取失败，返回默认占位符提示。",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Music (Compat)"
+                ],
+                "summary": "返回纯文本歌词 (旧版兼容)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "240479",
+                        "example": "240479",
+                        "description": "音乐 ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "netease",
+                        "example": "netease",
+                        "description": "平台",
+                        "name": "source",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "香水有毒",
+                        "example": "香水有毒",
+                        "description": "歌曲名称 (跨平台回退查找时必填)",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "胡杨林",
+                        "example": "胡杨林",
+                        "description": "歌手名称",
+                        "name": "artist",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 290,
+                        "description": "歌曲时长(秒)",
+                        "name": "duration",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "原平台无歌词时是否跨平台回退查找",
+                        "name": "fallback",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": -500,
+                        "description": "歌词时间偏移(毫秒)，正数表示歌词延后；留空则使用已保存的该曲校正值",
+                        "name": "offset_ms",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "LRC 文本",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/rest/getAlbum": {
+            "get": {
+                "description": "对应 /api/v1/album/detail。",
+                "produces": [
+                    "text/xml",
+                    "application/json"
+                ],
+                "tags": [
+                    "Subsonic"
+                ],
+                "summary": "Subsonic: getAlbum",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "专辑 ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subsonic-response",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/rest/getCoverArt": {
+            "get": {
+                "description": "对应 /api/v1/music/cover，按封面域名所属平台的请求头拉取图片；size 参数被忽略。id 为歌曲、专辑或歌单 ID，封面地址取自服务端保存的元数据，域名不属于该平台时拒绝访问。",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "Subsonic"
+                ],
+                "summary": "Subsonic: getCoverArt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "封面 ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "图片",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/rest/getLicense": {
+            "get": {
+                "description": "始终返回有效许可。",
+                "produces": [
+                    "text/xml",
+                    "application/json"
+                ],
+                "tags": [
+                    "Subsonic"
+                ],
+                "summary": "Subsonic: getLicense",
+                "responses": {
+                    "200": {
+                        "description": "subsonic-response",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/rest/getLyrics": {
+            "get": {
+                "description": "对应 /api/v1/music/lyric 的跨平台回退查找，按歌名与歌手匹配歌曲后返回去掉时间标签的纯文本歌词，找不到时返回空歌词。",
+                "produces": [
+                    "text/xml",
+                    "application/json"
+                ],
+                "tags": [
+                    "Subsonic"
+                ],
+                "summary": "Subsonic: getLyrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "歌手",
+                        "name": "artist",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "歌名",
+                        "name": "title",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subsonic-response",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/rest/getPlaylist": {
+            "get": {
+                "description": "对应 /api/v1/playlist/detail。",
+                "produces": [
+                    "text/xml",
+                    "application/json"
+                ],
+                "tags": [
+                    "Subsonic"
                 ],
-                "summary": "设置系统 Cookies",
+                "summary": "Subsonic: getPlaylist",
                 "parameters": [
                     {
-                        "description": "平台Cookies映射示例：{\\",
-                        "name": "cookies",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
+                        "type": "string",
+                        "description": "歌单 ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "操作成功",
-                        "schema": {
-                            "$ref": "#/definitions/handler.Response"
-                        }
-                    },
-                    "400": {
-                        "description": "参数解析失败",
+                        "description": "subsonic-response",
                         "schema": {
-                            "$ref": "#/definitions/handler.Response"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
             }
         },
-        "/api/v1/system/qr_login/sources": {
+        "/rest/getPlaylists": {
             "get": {
-                "description": "返回当前 API 支持创建二维码登录会话的平台列表。",
+                "description": "对应 /api/v1/playlist/user，汇总已配置 Cookie 的平台上登录账号的个人歌单。",
                 "produces": [
+                    "text/xml",
                     "application/json"
                 ],
                 "tags": [
-                    "System"
+                    "Subsonic"
                 ],
-                "summary": "获取支持扫码登录的平台",
+                "summary": "Subsonic: getPlaylists",
                 "responses": {
                     "200": {
-                        "description": "支持扫码登录的平台",
+                        "description": "subsonic-response",
                         "schema": {
-                            "$ref": "#/definitions/handler.Response"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
             }
         },
-        "/api/v1/system/qr_login/{source}": {
+        "/rest/getSong": {
             "get": {
-                "description": "使用创建扫码登录会话返回的 key 轮询登录状态；成功时自动写入 cookies.json。",
+                "description": "歌曲信息取自服务端保存的搜索与列表结果，不访问上游平台，服务重启后仍然有效；超过 180 天未再出现在结果中的 ID 只返回平台 ID。",
                 "produces": [
+                    "text/xml",
                     "application/json"
                 ],
                 "tags": [
-                    "System"
+                    "Subsonic"
                 ],
-                "summary": "轮询扫码登录状态",
+                "summary": "Subsonic: getSong",
                 "parameters": [
-                    {
-                        "enum": [
-                            "netease",
-                            "qq",
-                            "qq_wx",
-                            "kugou",
-                            "bilibili"
-                        ],
-                        "type": "string",
-                        "example": "qq_wx",
-                        "description": "扫码登录平台",
-                        "name": "source",
-                        "in": "path",
-                        "required": true
-                    },
                     {
                         "type": "string",
-                        "description": "扫码登录 key",
-                        "name": "key",
+                        "description": "歌曲 ID",
+                        "name": "id",
                         "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "扫码登录状态",
-                        "schema": {
-                            "$ref": "#/definitions/handler.Response"
-                        }
-                    },
-                    "400": {
-                        "description": "缺少 key",
-                        "schema": {
-                            "$ref": "#/definitions/handler.Response"
-                        }
-                    },
-                    "404": {
-                        "description": "平台不支持扫码登录",
+                        "description": "subsonic-response",
                         "schema": {
-                            "$ref": "#/definitions/handler.Response"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "为指定平台创建扫码登录会话，返回二维码 URL、二维码图片地址或平台登录 key。",
+            }
+        },
+        "/rest/ping": {
+            "get": {
+                "description": "供 Subsonic 客户端测试连接与鉴权。鉴权使用 u 与 p，或 u、t=md5(password+s) 与 s，也支持 OpenSubsonic 的 apiKey；f=json 时返回 JSON，默认 XML。以下 Subsonic 接口同时支持 .view 后缀与 POST 表单。",
                 "produces": [
+                    "text/xml",
                     "application/json"
                 ],
                 "tags": [
-                    "System"
+                    "Subsonic"
                 ],
-                "summary": "创建扫码登录会话",
+                "summary": "Subsonic: ping",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "API Key 名称或本地用户名",
+                        "name": "u",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API Key 或用户令牌，支持 enc: 十六进制编码",
+                        "name": "p",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "md5(password+salt)",
+                        "name": "t",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "盐",
+                        "name": "s",
+                        "in": "query"
+                    },
                     {
                         "enum": [
-                            "netease",
-                            "qq",
-                            "qq_wx",
-                            "kugou",
-                            "bilibili"
+                            "xml",
+                            "json"
                         ],
                         "type": "string",
-                        "example": "qq_wx",
-                        "description": "扫码登录平台",
-                        "name": "source",
-                        "in": "path",
-                        "required": true
+                        "description": "响应格式",
+                        "name": "f",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "扫码登录会话",
-                        "schema": {
-                            "$ref": "#/definitions/handler.Response"
-                        }
-                    },
-                    "404": {
-                        "description": "平台不支持扫码登录",
+                        "description": "subsonic-response",
                         "schema": {
-                            "$ref": "#/definitions/handler.Response"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
                 }
             }
         },
-        "/music/lyric": {
+        "/rest/search3": {
             "get": {
-                "description": "直接返回 ` + "`" + `text/plain` + "`" + ` 格式的纯歌词内容。若拉取失败，返回默认占位符提示。",
+                "description": "对应 /api/v1/music/search，并发搜索默认平台的单曲与专辑，按平台顺序合并后分页；不返回歌手。空关键词返回空结果。",
                 "produces": [
-                    "text/plain"
+                    "text/xml",
+                    "application/json"
                 ],
                 "tags": [
-                    "Music (Compat)"
+                    "Subsonic"
                 ],
-                "summary": "返回纯文本歌词 (旧版兼容)",
+                "summary": "Subsonic: search3",
                 "parameters": [
                     {
                         "type": "string",
-                        "default": "240479",
-                        "example": "240479",
-                        "description": "音乐 ID",
-                        "name": "id",
+                        "description": "关键词",
+                        "name": "query",
                         "in": "query",
                         "required": true
                     },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "歌曲数量",
+                        "name": "songCount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "歌曲偏移",
+                        "name": "songOffset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "专辑数量",
+                        "name": "albumCount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "专辑偏移",
+                        "name": "albumOffset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "subsonic-response",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/rest/stream": {
+            "get": {
+                "description": "对应 /api/v1/music/stream，支持 Range；不转码，maxBitRate 与 format 参数被忽略。",
+                "produces": [
+                    "audio/mpeg"
+                ],
+                "tags": [
+                    "Subsonic"
+                ],
+                "summary": "Subsonic: stream",
+                "parameters": [
                     {
                         "type": "string",
-                        "default": "netease",
-                        "example": "netease",
-                        "description": "平台",
-                        "name": "source",
+                        "description": "歌曲 ID",
+                        "name": "id",
                         "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "LRC 文本",
+                        "description": "音频流",
                         "schema": {
-                            "type": "string"
+                            "type": "file"
                         }
                     }
                 }
@@ -1007,12 +2755,123 @@ const docTemplate = `{
                     "example": 200
                 },
                 "data": {},
+                "error": {
+                    "description": "失败时的机器可读错误码，如 NOT_FOUND、AUTH_REQUIRED",
+                    "type": "string",
+                    "example": ""
+                },
                 "msg": {
                     "type": "string",
                     "example": "success"
                 }
             }
         },
+        "handler.cookieAccountLabelRequest": {
+            "type": "object",
+            "properties": {
+                "label": {
+                    "type": "string",
+                    "example": "团队 VIP 账号 1"
+                }
+            }
+        },
+        "handler.cookieAccountRequest": {
+            "type": "object",
+            "properties": {
+                "cookie": {
+                    "type": "string",
+                    "example": "MUSIC_U=xxx; __csrf=yyy;"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "vip-1"
+                },
+                "label": {
+                    "type": "string",
+                    "example": "团队 VIP 账号 1"
+                }
+            }
+        },
+        "handler.lyricOffsetRequest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string",
+                    "example": "240479"
+                },
+                "offset_ms": {
+                    "type": "integer",
+                    "example": -500
+                },
+                "source": {
+                    "type": "string",
+                    "example": "netease"
+                }
+            }
+        },
+        "handler.userCredentials": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "correct-horse-battery"
+                },
+                "token_name": {
+                    "type": "string",
+                    "example": "desktop"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "alice"
+                }
+            }
+        },
+        "handler.userTokenRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "bot"
+                }
+            }
+        },
+        "model.QRLoginResult": {
+            "type": "object",
+            "properties": {
+                "cookie": {
+                    "type": "string"
+                },
+                "cookies": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "extra": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.QRLoginStatus"
+                }
+            }
+        },
+        "model.QRLoginStatus": {
+            "type": "string",
+            "enum": [
+                "waiting",
+                "success"
+            ],
+            "x-enum-varnames": [
+                "QRLoginStatusWaiting",
+                "QRLoginStatusSuccess"
+            ]
+        },
         "model.Song": {
             "type": "object",
             "properties": {
@@ -1076,6 +2935,40 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "service.QREvent": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "result": {
+                    "$ref": "#/definitions/model.QRLoginResult"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "terminal": {
+                    "description": "为 true 时会话已结束，推送随之关闭",
+                    "type": "boolean"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
         }
     }
 }`
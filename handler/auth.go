@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+const (
+	authRoleKey = "auth_role"
+	authNameKey = "auth_name"
+	authUserKey = "auth_user"
+)
+
+// apiKeyFromRequest 依次从 X-API-Key、Authorization: Bearer 与 api_key 查询参数读取 API Key
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("X-API-Key")); key != "" {
+		return key
+	}
+	if auth := strings.TrimSpace(c.GetHeader("Authorization")); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return strings.TrimSpace(c.Query("api_key"))
+}
+
+func lookupAPIKey(key string) *config.APIKey {
+	for i := range config.C.Auth.Keys {
+		k := &config.C.Auth.Keys[i]
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			return k
+		}
+	}
+	return nil
+}
+
+// Auth 识别请求携带的 API Key 或用户令牌并记录调用方角色，用户令牌按 user 角色处理。
+// 未配置任何 API Key 时保持旧版行为，所有请求均视为管理员。
+func Auth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := apiKeyFromRequest(c)
+		if username, ok := service.US.LookupToken(key); ok {
+			role := config.RoleUser
+			if len(config.C.Auth.Keys) == 0 {
+				role = config.RoleAdmin
+			}
+			c.Set(authRoleKey, role)
+			c.Set(authNameKey, "user:"+username)
+			c.Set(authUserKey, username)
+			c.Next()
+			return
+		}
+		if len(config.C.Auth.Keys) == 0 {
+			c.Set(authRoleKey, config.RoleAdmin)
+			c.Next()
+			return
+		}
+		if key == "" {
+			c.Next()
+			return
+		}
+		k := lookupAPIKey(key)
+		if k == nil {
+			respondError(c, ErrCodeUnauthorized, "invalid api key", nil)
+			c.Abort()
+			return
+		}
+		c.Set(authRoleKey, k.Role)
+		c.Set(authNameKey, k.Name)
+		c.Next()
+	}
+}
+
+// roleRank 角色的权限高低，高的角色拥有低的角色的全部权限
+var roleRank = map[string]int{config.RoleUser: 1, config.RoleClient: 2, config.RoleAdmin: 3}
+
+// RequireRole 要求调用方具备指定角色：admin 拥有 client 的全部权限，client 拥有 user 的全部权限
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current := c.GetString(authRoleKey)
+		if current == "" {
+			respondError(c, ErrCodeUnauthorized, "missing api key", nil)
+			c.Abort()
+			return
+		}
+		if roleRank[current] < roleRank[role] {
+			respondError(c, ErrCodeForbidden, role+" role required", nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUser 要求请求携带有效的用户令牌
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentUser(c) == "" {
+			respondError(c, ErrCodeUnauthorized, "user token required", nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func isAdmin(c *gin.Context) bool {
+	return c.GetString(authRoleKey) == config.RoleAdmin
+}
+
+// currentUser 返回以用户令牌认证的用户名，其它调用方返回空串
+func currentUser(c *gin.Context) string {
+	return c.GetString(authUserKey)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+// loadTestUsers 在临时目录中打开用户库并创建一个用户，返回其令牌
+func loadTestUsers(t *testing.T) string {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := service.US.Load(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.US.Close() })
+	if _, err := service.US.Register("alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := service.US.CreateToken("alice", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newAuthTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Auth())
+	r.GET("/whoami", func(c *gin.Context) {
+		c.JSON(200, gin.H{"role": c.GetString(authRoleKey), "user": currentUser(c)})
+	})
+	r.GET("/admin", RequireRole(config.RoleAdmin), func(c *gin.Context) { c.Status(204) })
+	r.GET("/client", RequireRole(config.RoleClient), func(c *gin.Context) { c.Status(204) })
+	r.GET("/user", RequireRole(config.RoleUser), func(c *gin.Context) { c.Status(204) })
+	return r
+}
+
+func TestAuthRoleResolution(t *testing.T) {
+	userToken := loadTestUsers(t)
+	keys := []config.APIKey{
+		{Name: "ops", Key: "admin-key", Role: config.RoleAdmin},
+		{Name: "web", Key: "client-key", Role: config.RoleClient},
+	}
+
+	tests := []struct {
+		name   string
+		keys   []config.APIKey
+		key    string
+		status int
+		role   string
+		user   string
+	}{
+		{"no keys configured", nil, "", 200, config.RoleAdmin, ""},
+		{"no keys configured with user token", nil, userToken, 200, config.RoleAdmin, "alice"},
+		{"missing key", keys, "", 200, "", ""},
+		{"invalid key", keys, "wrong", 401, "", ""},
+		{"unknown user token", keys, service.UserTokenPrefix + "unknown", 401, "", ""},
+		{"client key", keys, "client-key", 200, config.RoleClient, ""},
+		{"admin key", keys, "admin-key", 200, config.RoleAdmin, ""},
+		{"user token", keys, userToken, 200, config.RoleUser, "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := config.C.Auth.Keys
+			t.Cleanup(func() { config.C.Auth.Keys = old })
+			config.C.Auth.Keys = tt.keys
+
+			req := httptest.NewRequest("GET", "/whoami", nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			w := httptest.NewRecorder()
+			newAuthTestRouter().ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != 200 {
+				return
+			}
+			var got struct{ Role, User string }
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Role != tt.role || got.User != tt.user {
+				t.Errorf("role, user = %q, %q; want %q, %q", got.Role, got.User, tt.role, tt.user)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	userToken := loadTestUsers(t)
+	old := config.C.Auth.Keys
+	t.Cleanup(func() { config.C.Auth.Keys = old })
+	config.C.Auth.Keys = []config.APIKey{
+		{Name: "ops", Key: "admin-key", Role: config.RoleAdmin},
+		{Name: "web", Key: "client-key", Role: config.RoleClient},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		status int
+	}{
+		{"admin route without key", "/admin", "", 401},
+		{"admin route with client key", "/admin", "client-key", 403},
+		{"admin route with user token", "/admin", userToken, 403},
+		{"admin route with admin key", "/admin", "admin-key", 204},
+		{"client route with user token", "/client", userToken, 403},
+		{"client route with client key", "/client", "client-key", 204},
+		{"client route with admin key", "/client", "admin-key", 204},
+		{"user route without key", "/user", "", 401},
+		{"user route with user token", "/user", userToken, 204},
+		{"user route with client key", "/user", "client-key", 204},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			newAuthTestRouter().ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+type cookieAccountRequest struct {
+	ID     string `json:"id" example:"vip-1"`
+	Label  string `json:"label" example:"团队 VIP 账号 1"`
+	Cookie string `json:"cookie" example:"MUSIC_U=xxx; __csrf=yyy;"`
+}
+
+type cookieAccountLabelRequest struct {
+	Label string `json:"label" example:"团队 VIP 账号 1"`
+}
+
+func cookieAccountSource(c *gin.Context) (string, bool) {
+	source := strings.TrimSpace(c.Param("source"))
+	if !slices.Contains(service.GetAllSourceNames(), source) {
+		respondError(c, ErrCodeSourceUnsupported, "unsupported source: "+source, nil)
+		return "", false
+	}
+	return source, true
+}
+
+func saveCookieAccounts(c *gin.Context, data interface{}) {
+	if err := service.CM.Save(); err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: data})
+}
+
+// GetCookieAccounts 列出 Cookie 账号池
+// @Summary 列出各平台的 Cookie 账号池
+// @Description 返回每个平台配置的全部账号及其使用时间、连续鉴权失败次数与隔离状态。非管理员 API Key 只能看到脱敏后的 Cookie 值。
+// @Tags System
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} Response "按平台分组的账号列表"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Router /api/v1/system/cookies/accounts [get]
+func GetCookieAccounts(c *gin.Context) {
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: service.CM.Accounts(!isAdmin(c))})
+}
+
+// AddCookieAccount 添加 Cookie 账号
+// @Summary 向平台账号池添加账号
+// @Description 为指定平台追加一个 Cookie 账号，请求按配置的轮询或最近最少使用策略在账号间分配。id 留空时自动生成。
+// @Tags System
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param source path string true "音乐平台" example(netease)
+// @Param account body cookieAccountRequest true "账号信息"
+// @Success 200 {object} Response "新增的账号"
+// @Failure 400 {object} Response "参数错误或账号 ID 已存在"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
+// @Failure 500 {object} Response "保存失败"
+// @Router /api/v1/system/cookies/accounts/{source} [post]
+func AddCookieAccount(c *gin.Context) {
+	source, ok := cookieAccountSource(c)
+	if !ok {
+		return
+	}
+	var req cookieAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeBadRequest, "Invalid JSON", nil)
+		return
+	}
+	if strings.TrimSpace(req.Cookie) == "" {
+		respondError(c, ErrCodeMissingParameter, "missing cookie", nil)
+		return
+	}
+	account, err := service.CM.AddAccount(source, req.ID, req.Label, req.Cookie)
+	if err != nil {
+		respondError(c, ErrCodeBadRequest, err.Error(), nil)
+		return
+	}
+	account.Cookie = service.MaskCookie(account.Cookie)
+	saveCookieAccounts(c, account)
+}
+
+// LabelCookieAccount 修改 Cookie 账号备注
+// @Summary 修改账号备注
+// @Tags System
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param source path string true "音乐平台" example(netease)
+// @Param id path string true "账号 ID"
+// @Param label body cookieAccountLabelRequest true "账号备注"
+// @Success 200 {object} Response "修改后的账号"
+// @Failure 400 {object} Response "参数错误"
+// @Failure 404 {object} Response "账号不存在"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
+// @Router /api/v1/system/cookies/accounts/{source}/{id} [put]
+func LabelCookieAccount(c *gin.Context) {
+	source, ok := cookieAccountSource(c)
+	if !ok {
+		return
+	}
+	var req cookieAccountLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeBadRequest, "Invalid JSON", nil)
+		return
+	}
+	account, err := service.CM.LabelAccount(source, c.Param("id"), req.Label)
+	if err != nil {
+		respondSourceError(c, source, err, nil)
+		return
+	}
+	account.Cookie = service.MaskCookie(account.Cookie)
+	saveCookieAccounts(c, account)
+}
+
+// RemoveCookieAccount 删除 Cookie 账号
+// @Summary 从平台账号池删除账号
+// @Tags System
+// @Produce json
+// @Security ApiKeyAuth
+// @Param source path string true "音乐平台" example(netease)
+// @Param id path string true "账号 ID"
+// @Success 200 {object} Response "操作成功"
+// @Failure 404 {object} Response "账号不存在"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
+// @Router /api/v1/system/cookies/accounts/{source}/{id} [delete]
+func RemoveCookieAccount(c *gin.Context) {
+	source, ok := cookieAccountSource(c)
+	if !ok {
+		return
+	}
+	if err := service.CM.RemoveAccount(source, c.Param("id")); err != nil {
+		respondSourceError(c, source, err, nil)
+		return
+	}
+	saveCookieAccounts(c, nil)
+}
+
+// GetCookieStatus 查看 Cookie 校验状态
+// @Summary 查看各账号 Cookie 的校验状态
+// @Description 返回后台定期校验的结果：最近校验时间、最近一次校验通过的时间、从 Cookie 字段解析出的过期时间与隔离状态。管理员可传 refresh=true 立即重新校验。
+// @Tags System
+// @Produce json
+// @Security ApiKeyAuth
+// @Param refresh query bool false "立即重新校验(仅管理员)"
+// @Success 200 {object} Response "各账号的校验状态"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
+// @Router /api/v1/system/cookies/status [get]
+func GetCookieStatus(c *gin.Context) {
+	if parseBoolQuery(c, "refresh", false) {
+		if !isAdmin(c) {
+			respondError(c, ErrCodeForbidden, "admin api key required", nil)
+			return
+		}
+		service.CHC.CheckAll()
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: service.CHC.Statuses()})
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+// CookieHeaderPrefix 请求自带平台 Cookie 的请求头前缀，如 X-Music-Cookie-netease
+const CookieHeaderPrefix = "X-Music-Cookie-"
+
+// requestCookies 读取请求头中自带的各平台 Cookie
+func requestCookies(c *gin.Context) service.Cookies {
+	var cookies service.Cookies
+	for name, values := range c.Request.Header {
+		if len(name) <= len(CookieHeaderPrefix) || !strings.EqualFold(name[:len(CookieHeaderPrefix)], CookieHeaderPrefix) {
+			continue
+		}
+		source := strings.ToLower(name[len(CookieHeaderPrefix):])
+		if !slices.Contains(service.GetAllSourceNames(), source) || len(values) == 0 {
+			continue
+		}
+		if cookies == nil {
+			cookies = make(service.Cookies)
+		}
+		cookies[source] = values[0]
+	}
+	return cookies
+}
+
+// CookieOverride 按请求选择平台 Cookie：请求头自带的优先，其次是登录用户保险箱中的，
+// 都没有时才使用全局账号池。需挂载在 Auth 之后。
+func CookieOverride() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if username := currentUser(c); username != "" {
+			ctx = service.WithCookies(ctx, service.US.VaultCookies(username))
+		}
+		ctx = service.WithCookies(ctx, requestCookies(c))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+type corsMatcher struct {
+	any      bool
+	exact    map[string]bool
+	wildcard [][2]string // 子域名通配的前缀与后缀，如 {"https://", ".example.com"}
+}
+
+func newCORSMatcher(origins []string) *corsMatcher {
+	m := &corsMatcher{exact: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "":
+		case origin == "*":
+			m.any = true
+		case strings.Contains(origin, "*."):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			m.wildcard = append(m.wildcard, [2]string{prefix, suffix})
+		default:
+			m.exact[origin] = true
+		}
+	}
+	return m
+}
+
+func (m *corsMatcher) allowed(origin string) bool {
+	if m.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return true
+	}
+	for _, w := range m.wildcard {
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS 按策略处理跨域请求与预检，可分别挂载到不同路由组。
+// 路由组需同时注册 OPTIONS 通配路由，预检请求才会进入该中间件。
+func CORS(policy config.CORSPolicy) gin.HandlerFunc {
+	matcher := newCORSMatcher(policy.AllowedOrigins)
+	methods := strings.Join(policy.AllowedMethods, ", ")
+	var fixedHeaders, headerPrefixes []string
+	for _, h := range policy.AllowedHeaders {
+		if prefix, ok := strings.CutSuffix(h, "*"); ok {
+			headerPrefixes = append(headerPrefixes, strings.ToLower(prefix))
+		} else {
+			fixedHeaders = append(fixedHeaders, h)
+		}
+	}
+	headers := strings.Join(fixedHeaders, ", ")
+	exposed := strings.Join(policy.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(policy.MaxAge)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == "OPTIONS"
+		if origin == "" {
+			if preflight {
+				c.AbortWithStatus(204)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if !matcher.allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(403)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if matcher.any && !policy.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if policy.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if exposed != "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+		}
+
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", strings.TrimPrefix(headers+requestedHeaders(c, headerPrefixes), ", "))
+			if policy.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Next()
+	}
+}
+
+// requestedHeaders 返回预检请求中匹配前缀通配的请求头，拼接在固定的允许列表之后
+func requestedHeaders(c *gin.Context, prefixes []string) string {
+	if len(prefixes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, name := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+		name = strings.TrimSpace(name)
+		lower := strings.ToLower(name)
+		for _, prefix := range prefixes {
+			if len(lower) > len(prefix) && strings.HasPrefix(lower, prefix) {
+				b.WriteString(", " + name)
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// Preflight 供路由组注册 OPTIONS 通配路由，实际响应由 CORS 中间件完成
+func Preflight(c *gin.Context) {
+	c.Status(204)
+}
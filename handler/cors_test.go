@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+func newCORSTestRouter(policy config.CORSPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	g := r.Group("/", CORS(policy))
+	g.OPTIONS("/*path", Preflight)
+	g.GET("/ping", func(c *gin.Context) { c.String(200, "pong") })
+	return r
+}
+
+func TestCORS(t *testing.T) {
+	restricted := config.CORSPolicy{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-Music-Cookie-*"},
+		MaxAge:         600,
+	}
+	credentials := restricted
+	credentials.AllowCredentials = true
+	open := config.CORSPolicy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}
+
+	tests := []struct {
+		name        string
+		policy      config.CORSPolicy
+		method      string
+		origin      string
+		reqHeaders  string
+		status      int
+		allowOrigin string
+		allowCreds  string
+		allowHeads  string
+	}{
+		{"preflight allowed", restricted, "OPTIONS", "https://app.example.com", "content-type, X-Music-Cookie-qq, X-Evil", 204, "https://app.example.com", "", "Content-Type, X-Music-Cookie-qq"},
+		{"preflight subdomain wildcard", restricted, "OPTIONS", "https://a.example.org", "", 204, "https://a.example.org", "", "Content-Type"},
+		{"preflight bare wildcard domain", restricted, "OPTIONS", "https://example.org", "", 403, "", "", ""},
+		{"preflight disallowed", restricted, "OPTIONS", "https://evil.test", "", 403, "", "", ""},
+		{"preflight without origin", restricted, "OPTIONS", "", "", 204, "", "", ""},
+		{"simple allowed", restricted, "GET", "https://app.example.com", "", 200, "https://app.example.com", "", ""},
+		{"simple disallowed", restricted, "GET", "https://evil.test", "", 200, "", "", ""},
+		{"credentials echo origin", credentials, "GET", "https://app.example.com", "", 200, "https://app.example.com", "true", ""},
+		{"any origin without credentials", open, "GET", "https://evil.test", "", 200, "*", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/ping", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.reqHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.reqHeaders)
+			}
+			w := httptest.NewRecorder()
+			newCORSTestRouter(tt.policy).ServeHTTP(w, req)
+
+			h := w.Header()
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.allowOrigin)
+			}
+			if got := h.Get("Access-Control-Allow-Credentials"); got != tt.allowCreds {
+				t.Errorf("Allow-Credentials = %q, want %q", got, tt.allowCreds)
+			}
+			if got := h.Get("Access-Control-Allow-Headers"); got != tt.allowHeads {
+				t.Errorf("Allow-Headers = %q, want %q", got, tt.allowHeads)
+			}
+			if tt.origin != "" && h.Get("Vary") != "Origin" {
+				t.Errorf("Vary = %q, want Origin", h.Get("Vary"))
+			}
+			if tt.method == http.MethodOptions && tt.status == 204 && tt.allowOrigin != "" {
+				if h.Get("Access-Control-Allow-Methods") != "GET, POST" || h.Get("Access-Control-Max-Age") != "600" {
+					t.Errorf("preflight headers = %v", h)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+// GetEgress 查看各平台的出站路径
+// @Summary 查看各平台的出站路径
+// @Description 返回各平台请求实际使用的出口：direct(直连)或 proxy(经 proxy 配置的代理，密码已隐去)，以及用于识别平台请求的域名。传入 url 时返回访问该地址会选择的出口。管理员可传 test=true 经对应出口实际发送一次 HEAD 请求，收到任意 HTTP 响应即视为连通。
+// @Tags System
+// @Produce json
+// @Security ApiKeyAuth
+// @Param source query string false "只返回指定平台，多个用逗号分隔" example(joox)
+// @Param url query string false "查询访问该地址时选择的出口" example(https://api.joox.com/)
+// @Param test query bool false "经出口发送测试请求(仅管理员)"
+// @Success 200 {object} Response "各平台出站路径"
+// @Failure 400 {object} Response "url 格式错误"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
+// @Router /api/v1/system/egress [get]
+func GetEgress(c *gin.Context) {
+	test := parseBoolQuery(c, "test", false)
+	if test && !isAdmin(c) {
+		respondError(c, ErrCodeForbidden, "admin api key required", nil)
+		return
+	}
+
+	var egress []service.Egress
+	if rawURL := strings.TrimSpace(c.Query("url")); rawURL != "" {
+		e, err := service.EgressForURL(rawURL)
+		if err != nil {
+			respondError(c, ErrCodeBadRequest, "invalid url", nil)
+			return
+		}
+		egress = append(egress, e)
+	} else {
+		var filter []string
+		for _, s := range strings.Split(c.Query("source"), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				filter = append(filter, s)
+			}
+		}
+		for _, source := range service.GetAllSourceNames() {
+			if len(filter) == 0 || slices.Contains(filter, source) {
+				egress = append(egress, service.EgressFor(source))
+			}
+		}
+	}
+
+	if test {
+		var wg sync.WaitGroup
+		for i := range egress {
+			wg.Add(1)
+			go func(e *service.Egress) {
+				defer wg.Done()
+				service.TestEgress(c.Request.Context(), e)
+			}(&egress[i])
+		}
+		wg.Wait()
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: egress})
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+// 错误码目录：/api/v1 下的接口失败时统一返回 Response，并在 error 字段给出以下稳定错误码
+const (
+	ErrCodeBadRequest        = "BAD_REQUEST"
+	ErrCodeMissingParameter  = "MISSING_PARAMETER"
+	ErrCodeSourceUnsupported = "SOURCE_UNSUPPORTED"
+	ErrCodeUnauthorized      = "UNAUTHORIZED"
+	ErrCodeForbidden         = "FORBIDDEN"
+	ErrCodeTooManyRequests   = "TOO_MANY_REQUESTS"
+	ErrCodeNotFound          = "NOT_FOUND"
+	ErrCodeQRSessionExpired  = "QR_SESSION_EXPIRED"
+	ErrCodeAuthRequired      = "AUTH_REQUIRED"
+	ErrCodePaidContent       = "PAID_CONTENT"
+	ErrCodeRateLimited       = "RATE_LIMITED"
+	ErrCodeUpstreamTimeout   = "UPSTREAM_TIMEOUT"
+	ErrCodeCircuitOpen       = "CIRCUIT_OPEN"
+	ErrCodeUpstream          = "UPSTREAM_ERROR"
+	ErrCodeInternal          = "INTERNAL_ERROR"
+)
+
+var errorCodeStatus = map[string]int{
+	ErrCodeBadRequest:        400,
+	ErrCodeMissingParameter:  400,
+	ErrCodeSourceUnsupported: 400,
+	ErrCodeUnauthorized:      401,
+	ErrCodeForbidden:         403,
+	ErrCodeTooManyRequests:   429,
+	ErrCodeNotFound:          404,
+	ErrCodeQRSessionExpired:  410,
+	ErrCodeAuthRequired:      401,
+	ErrCodePaidContent:       402,
+	ErrCodeRateLimited:       429,
+	ErrCodeUpstreamTimeout:   504,
+	ErrCodeCircuitOpen:       503,
+	ErrCodeUpstream:          502,
+	ErrCodeInternal:          500,
+}
+
+const compatModeKey = "compat_mode"
+
+// CompatMode 标记兼容路由组的请求，出错时沿用原 server.go 的响应格式
+func CompatMode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(compatModeKey, true)
+		c.Next()
+	}
+}
+
+func isCompat(c *gin.Context) bool {
+	return c.GetBool(compatModeKey)
+}
+
+// legacyResponse 兼容路由组的旧版响应，保证 /music 下的输出与原实现逐字节一致
+type legacyResponse struct {
+	status int
+	body   interface{} // string 按纯文本输出，其余按 JSON 输出
+}
+
+func legacy(status int, body interface{}) *legacyResponse {
+	return &legacyResponse{status: status, body: body}
+}
+
+func (l *legacyResponse) write(c *gin.Context) {
+	if text, ok := l.body.(string); ok {
+		if text == "" {
+			c.Status(l.status)
+			return
+		}
+		c.String(l.status, text)
+		return
+	}
+	c.JSON(l.status, l.body)
+}
+
+// sourceErrorCode 将服务层错误类型映射为错误码
+func sourceErrorCode(err error) string {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return ErrCodeNotFound
+	case errors.Is(err, service.ErrQRSessionExpired):
+		return ErrCodeQRSessionExpired
+	case errors.Is(err, service.ErrAuthRequired):
+		return ErrCodeAuthRequired
+	case errors.Is(err, service.ErrPaidContent):
+		return ErrCodePaidContent
+	case errors.Is(err, service.ErrRateLimited):
+		return ErrCodeRateLimited
+	case errors.Is(err, service.ErrTimeout):
+		return ErrCodeUpstreamTimeout
+	case errors.Is(err, service.ErrCircuitOpen):
+		return ErrCodeCircuitOpen
+	default:
+		return ErrCodeUpstream
+	}
+}
+
+// respondError 按错误码目录返回统一错误响应；兼容路由组传入 old 时改为输出旧版响应，
+// Subsonic 接口改为输出 Subsonic 错误
+func respondError(c *gin.Context, code, msg string, old *legacyResponse) {
+	if isSubsonic(c) {
+		subsonicFail(c, subsonicErrorCodes[code], msg)
+		return
+	}
+	if old != nil && isCompat(c) {
+		old.write(c)
+		return
+	}
+	status := errorCodeStatus[code]
+	if status == 429 && c.Writer.Header().Get("Retry-After") == "" {
+		c.Header("Retry-After", "1")
+	}
+	c.JSON(status, Response{Code: status, Msg: msg, Error: code})
+}
+
+// respondSourceError 按平台错误类型返回对应状态码的统一错误响应
+func respondSourceError(c *gin.Context, source string, err error, old *legacyResponse) {
+	err = service.WrapSourceError(source, err)
+	if errors.Is(err, service.ErrCircuitOpen) {
+		if wait := time.Until(service.CB.State(source).RetryAt); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+	}
+	respondError(c, sourceErrorCode(err), err.Error(), old)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+var startedAt = time.Now()
+
+// Health 存活检查
+// @Summary 存活检查
+// @Description 服务进程存活时返回 ok 与运行时长，不访问任何上游平台，无需 API Key，可用于负载均衡与容器健康检查。
+// @Tags System
+// @Produce json
+// @Success 200 {object} Response "服务存活"
+// @Router /api/v1/system/health [get]
+func Health(c *gin.Context) {
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: gin.H{
+		"status":         "ok",
+		"started_at":     startedAt,
+		"uptime_seconds": int64(time.Since(startedAt).Seconds()),
+	}})
+}
+
+// GetSourceHealth 查看各平台合成探测结果
+// @Summary 查看各平台合成探测结果
+// @Description 返回各平台最近一次合成探测的结果。探测依次执行：用配置的关键词搜索、对第一首结果获取下载链接、对链接发起 Range 请求，分别对应 search、download_url、playable 阶段，可据此区分"搜索失效"与"链接返回 403"。状态为 ok、degraded(搜索可用但链接或播放失败)、down(搜索失败)、pending(尚未探测)或 disabled。后台按 health.probe_minutes 定期探测，管理员可传 refresh=true 立即重新探测。
+// @Tags System
+// @Produce json
+// @Security ApiKeyAuth
+// @Param source query string false "只返回指定平台，多个用逗号分隔" example(kuwo)
+// @Param refresh query bool false "立即重新探测(仅管理员)"
+// @Success 200 {object} Response "各平台探测结果"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
+// @Router /api/v1/system/health/sources [get]
+func GetSourceHealth(c *gin.Context) {
+	var filter []string
+	for _, s := range strings.Split(c.Query("source"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			filter = append(filter, s)
+		}
+	}
+	if parseBoolQuery(c, "refresh", false) {
+		if !isAdmin(c) {
+			respondError(c, ErrCodeForbidden, "admin api key required", nil)
+			return
+		}
+		if len(filter) == 0 {
+			service.SP.ProbeAll()
+		}
+		for _, source := range filter {
+			if slices.Contains(service.GetAllSourceNames(), source) {
+				service.SP.Probe(source)
+			}
+		}
+	}
+
+	results := service.SP.Results()
+	if len(filter) > 0 {
+		wanted := make(map[string]bool, len(filter))
+		for _, s := range filter {
+			wanted[s] = true
+		}
+		filtered := results[:0]
+		for _, r := range results {
+			if wanted[r.Source] {
+				filtered = append(filtered, r)
+			}
+		}
+		results = filtered
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: results})
+}
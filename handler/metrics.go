@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/metrics"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics 按路由模板统计请求数与耗时，未匹配路由的请求记为 unmatched，避免路径参数撑大标签基数
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+		metrics.HTTPRequests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+var metricsHandler = promhttp.Handler()
+
+// GetMetrics 导出 Prometheus 指标
+// @Summary Prometheus 指标
+// @Description 以 Prometheus 文本格式导出指标：各路由的请求数与耗时，各平台各能力的上游调用数(按结果与错误类型区分)与耗时，串流字节数，汽水音乐解密耗时，以及 Go 运行时指标。配置了 API Key 时需要 client 角色，抓取时可使用 Bearer 鉴权。
+// @Tags System
+// @Produce plain
+// @Security ApiKeyAuth
+// @Success 200 {string} string "Prometheus 文本格式指标"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Router /metrics [get]
+func GetMetrics(c *gin.Context) {
+	metricsHandler.ServeHTTP(c.Writer, c.Request)
+}
@@ -2,11 +2,15 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,52 +19,19 @@ import (
 	"unicode"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/metrics"
+	"github.com/guohuiyuan/go-music-api/qrcode"
 	"github.com/guohuiyuan/go-music-api/service"
 	"github.com/guohuiyuan/music-lib/model"
 	"github.com/guohuiyuan/music-lib/soda"
-	"github.com/guohuiyuan/music-lib/utils"
 )
 
 // Response 统一响应结构体
 type Response struct {
-	Code int         `json:"code" example:"200"`
-	Msg  string      `json:"msg" example:"success"`
-	Data interface{} `json:"data,omitempty"`
-}
-
-const (
-	UA_Common    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/134.0.0.0 Safari/537.36"
-	UA_Mobile    = "Mozilla/5.0 (iPhone; CPU iPhone OS 9_1 like Mac OS X) AppleWebKit/601.1.46 (KHTML, like Gecko) Version/9.0 Mobile/13B143 Safari/601.1"
-	Ref_Netease  = "http://music.163.com/"
-	Ref_Bilibili = "https://www.bilibili.com/"
-	Ref_Migu     = "http://music.migu.cn/"
-)
-
-// 辅助函数：构造带有 Cookie 和防盗链的 Request
-func buildReq(method, urlStr, source, rangeHeader string) (*http.Request, error) {
-	req, err := http.NewRequest(method, urlStr, nil)
-	if err != nil {
-		return nil, err
-	}
-	if rangeHeader != "" {
-		req.Header.Set("Range", rangeHeader)
-	}
-	req.Header.Set("User-Agent", UA_Common)
-	if source == "bilibili" {
-		req.Header.Set("Referer", Ref_Bilibili)
-	} else if source == "netease" {
-		req.Header.Set("Referer", Ref_Netease)
-	} else if source == "migu" {
-		req.Header.Set("User-Agent", UA_Mobile)
-		req.Header.Set("Referer", Ref_Migu)
-	} else if source == "qq" {
-		req.Header.Set("Referer", "http://y.qq.com")
-	}
-
-	if cookie := service.CM.Get(source); cookie != "" {
-		req.Header.Set("Cookie", cookie)
-	}
-	return req, nil
+	Code  int         `json:"code" example:"200"`
+	Msg   string      `json:"msg" example:"success"`
+	Error string      `json:"error,omitempty" example:""` // 失败时的机器可读错误码，如 NOT_FOUND、AUTH_REQUIRED
+	Data  interface{} `json:"data,omitempty"`
 }
 
 // 辅助函数：设置文件下载 Header
@@ -128,18 +99,32 @@ func parsePositiveIntQuery(c *gin.Context, name string, fallback int) int {
 	return value
 }
 
+func parseBoolQuery(c *gin.Context, name string, fallback bool) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(c.Query(name)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 // ==========================================
 // 系统配置相关接口
 // ==========================================
 
 // GetCookies 获取当前系统配置的 Cookies
 // @Summary 获取当前系统加载的 Cookies
-// @Description 读取并在 JSON 格式下返回当前系统已配置的各平台 Cookies。
+// @Description 读取并在 JSON 格式下返回当前系统已配置的各平台 Cookies。非管理员 API Key 只能看到脱敏后的 Cookie 值。
 // @Tags System
 // @Produce json
+// @Security ApiKeyAuth
 // @Success 200 {object} map[string]string "成功返回各平台 Cookie 键值对"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
 // @Router /api/v1/system/cookies [get]
 func GetCookies(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(200, service.CM.GetAllMasked())
+		return
+	}
 	c.JSON(200, service.CM.GetAll())
 }
 
@@ -149,56 +134,40 @@ func GetCookies(c *gin.Context) {
 // @Tags System
 // @Accept json
 // @Produce json
+// @Security ApiKeyAuth
 // @Param cookies body map[string]string true "平台Cookies映射示例：{\"netease\": \"os=pc;\", \"qq\": \"...\"}"
 // @Success 200 {object} Response "操作成功"
 // @Failure 400 {object} Response "参数解析失败"
+// @Failure 500 {object} Response "保存失败"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
 // @Router /api/v1/system/cookies [post]
 func SetCookies(c *gin.Context) {
 	var req map[string]string
-	if err := c.ShouldBindJSON(&req); err == nil {
-		service.CM.SetAll(req)
-		if err := service.CM.Save(); err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(200, gin.H{"status": "ok"})
-	} else {
-		c.JSON(400, gin.H{"error": "Invalid JSON"})
-	}
-}
-
-func qrLoginCookieString(result *model.QRLoginResult) string {
-	if result == nil {
-		return ""
-	}
-	if cookie := strings.TrimSpace(result.Cookie); cookie != "" {
-		return cookie
-	}
-	if len(result.Cookies) == 0 {
-		return ""
-	}
-	keys := make([]string, 0, len(result.Cookies))
-	for key := range result.Cookies {
-		if strings.TrimSpace(key) != "" {
-			keys = append(keys, key)
-		}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeBadRequest, "Invalid JSON", legacy(400, gin.H{"error": "Invalid JSON"}))
+		return
 	}
-	sort.Strings(keys)
-	parts := make([]string, 0, len(keys))
-	for _, key := range keys {
-		value := strings.TrimSpace(result.Cookies[key])
-		if value != "" {
-			parts = append(parts, key+"="+value)
-		}
+	service.CM.SetAll(req)
+	if err := service.CM.Save(); err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), legacy(500, gin.H{"error": err.Error()}))
+		return
 	}
-	return strings.Join(parts, "; ")
+	c.JSON(200, gin.H{"status": "ok"})
 }
 
-func qrLoginCookieSource(source string) string {
-	if source == "qq_wx" {
-		return "qq"
-	}
-	return source
+// GetQRLoginSessions 查看扫码登录会话
+// @Summary 查看扫码登录会话
+// @Description 列出服务端记录的扫码登录会话(平台、key、状态、创建者、创建与过期时间)，便于管理员查看等待中的登录。已过期的会话保留 10 分钟。
+// @Tags System
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} Response "扫码登录会话列表"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 403 {object} Response "需要管理员权限"
+// @Router /api/v1/system/qr_login/sessions [get]
+func GetQRLoginSessions(c *gin.Context) {
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: service.QRM.List()})
 }
 
 // GetQRLoginSources 获取支持扫码登录的平台
@@ -206,7 +175,9 @@ func qrLoginCookieSource(source string) string {
 // @Description 返回当前 API 支持创建二维码登录会话的平台列表。
 // @Tags System
 // @Produce json
+// @Security ApiKeyAuth
 // @Success 200 {object} Response "支持扫码登录的平台"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
 // @Router /api/v1/system/qr_login/sources [get]
 func GetQRLoginSources(c *gin.Context) {
 	sources := service.GetQRLoginSourceNames()
@@ -222,73 +193,205 @@ func GetQRLoginSources(c *gin.Context) {
 
 // CreateQRLogin 创建扫码登录会话
 // @Summary 创建扫码登录会话
-// @Description 为指定平台创建扫码登录会话，返回二维码 URL、二维码图片地址或平台登录 key。
+// @Description 为指定平台创建扫码登录会话，返回二维码 URL、二维码图片地址或平台登录 key。会话在 qr_login.session_ttl_seconds 后过期，过期时间见 extra.expires_at。
 // @Tags System
 // @Produce json
+// @Security ApiKeyAuth
 // @Param source path string true "扫码登录平台" Enums(netease,qq,qq_wx,kugou,bilibili) example(qq_wx)
 // @Success 200 {object} Response "扫码登录会话"
-// @Failure 404 {object} Response "平台不支持扫码登录"
+// @Failure 400 {object} Response "平台不支持扫码登录"
+// @Failure 502 {object} Response "上游平台请求失败"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
 // @Router /api/v1/system/qr_login/{source} [post]
 func CreateQRLogin(c *gin.Context) {
 	source := strings.TrimSpace(c.Param("source"))
-	fn := service.GetQRLoginCreateFunc(source)
+	fn := service.GetQRLoginCreateFunc(c.Request.Context(), source)
 	if fn == nil {
-		c.JSON(404, Response{Code: 404, Msg: "unsupported qr login source"})
+		respondError(c, ErrCodeSourceUnsupported, "unsupported qr login source", legacy(404, Response{Code: 404, Msg: "unsupported qr login source"}))
 		return
 	}
 	session, err := fn()
+	if err == nil {
+		var tracked service.QRSession
+		tracked, err = service.QRM.Create(source, session, c.GetString(authNameKey), currentUser(c), isAdmin(c))
+		if err == nil && !isCompat(c) {
+			if session.Extra == nil {
+				session.Extra = make(map[string]string)
+			}
+			session.Extra["expires_at"] = tracked.ExpiresAt.Format(time.RFC3339)
+		}
+	}
 	if err != nil {
-		c.JSON(502, Response{Code: 502, Msg: err.Error()})
+		respondSourceError(c, source, err, legacy(502, Response{Code: 502, Msg: err.Error()}))
 		return
 	}
 	c.JSON(200, Response{Code: 200, Msg: "success", Data: session})
 }
 
+// qrSession 返回调用方有权访问的扫码登录会话，只有创建者与管理员可以访问
+func qrSession(c *gin.Context, source, key string) (service.QRSession, bool) {
+	session, err := service.QRM.Get(source, key)
+	if err == nil && !isAdmin(c) && session.CreatedBy != c.GetString(authNameKey) {
+		err = service.NewSourceError(source, service.ErrNotFound, errors.New("unknown qr login key"))
+	}
+	if err != nil {
+		respondSourceError(c, source, err, legacy(404, Response{Code: 404, Msg: err.Error()}))
+		return session, false
+	}
+	return session, true
+}
+
 // CheckQRLogin 轮询扫码登录状态
 // @Summary 轮询扫码登录状态
-// @Description 使用创建扫码登录会话返回的 key 轮询登录状态；成功时自动写入 cookies.json。
+// @Description 使用创建扫码登录会话返回的 key 轮询登录状态，只接受本服务创建且未过期的会话，且只有创建者与管理员可以轮询。成功时：会话由用户令牌创建则写入该用户的 Cookie 保险箱，由管理员创建则写入 cookies.json，其它 API Key 只返回 Cookie 不保存。保存失败时 extra 中 cookie_saved 为 false、cookie_error 为原因，再次轮询会重新保存。
 // @Tags System
 // @Produce json
+// @Security ApiKeyAuth
 // @Param source path string true "扫码登录平台" Enums(netease,qq,qq_wx,kugou,bilibili) example(qq_wx)
 // @Param key query string true "扫码登录 key"
 // @Success 200 {object} Response "扫码登录状态"
+// @Failure 404 {object} Response "未知的扫码登录 key"
+// @Failure 410 {object} Response "扫码登录会话已过期"
 // @Failure 400 {object} Response "缺少 key"
-// @Failure 404 {object} Response "平台不支持扫码登录"
+// @Failure 400 {object} Response "平台不支持扫码登录"
+// @Failure 502 {object} Response "上游平台请求失败"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
 // @Router /api/v1/system/qr_login/{source} [get]
 func CheckQRLogin(c *gin.Context) {
 	source := strings.TrimSpace(c.Param("source"))
 	key := strings.TrimSpace(c.Query("key"))
 	if key == "" {
-		c.JSON(400, Response{Code: 400, Msg: "missing qr login key"})
+		respondError(c, ErrCodeMissingParameter, "missing qr login key", legacy(400, Response{Code: 400, Msg: "missing qr login key"}))
 		return
 	}
-	fn := service.GetQRLoginCheckFunc(source)
+	fn := service.GetQRLoginCheckFunc(c.Request.Context(), source)
 	if fn == nil {
-		c.JSON(404, Response{Code: 404, Msg: "unsupported qr login source"})
+		respondError(c, ErrCodeSourceUnsupported, "unsupported qr login source", legacy(404, Response{Code: 404, Msg: "unsupported qr login source"}))
+		return
+	}
+	if _, ok := qrSession(c, source, key); !ok {
 		return
 	}
-	result, err := fn(key)
+	result, err := service.QRM.Check(c.Request.Context(), source, key)
 	if err != nil {
-		c.JSON(502, Response{Code: 502, Msg: err.Error()})
+		respondSourceError(c, source, err, legacy(502, Response{Code: 502, Msg: err.Error()}))
 		return
 	}
-	if result != nil && result.Status == model.QRLoginStatusSuccess {
-		cookie := qrLoginCookieString(result)
-		if cookie != "" {
-			cookieSource := qrLoginCookieSource(source)
-			result.Cookie = cookie
-			service.CM.SetAll(map[string]string{cookieSource: cookie})
-			if err := service.CM.Save(); err == nil {
-				if result.Extra == nil {
-					result.Extra = make(map[string]string)
-				}
-				result.Extra["cookie_saved"] = "true"
-				result.Extra["cookie_source"] = cookieSource
-				result.Extra["cookie_length"] = strconv.Itoa(len(cookie))
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: result})
+}
+
+// GetQRLoginEvents 推送扫码登录状态
+// @Summary 订阅扫码登录状态(SSE)
+// @Description 以 Server-Sent Events 推送扫码登录会话的状态变化，替代客户端反复轮询。服务端按 qr_login.poll_interval_seconds 轮询平台，同一会话的多个订阅者共享一个轮询；每次状态变化(waiting、scanned、confirmed、success、expired 等)推送一条 status 事件，首条为当前状态。登录成功时 Cookie 的保存规则与轮询接口相同，保存失败时事件的 message 注明原因且不结束推送，之后的轮询会重新保存；会话结束(terminal 为 true)后连接关闭。轮询平台失败时推送 state 为 error 的事件并继续轮询。只有创建者与管理员可以订阅，浏览器 EventSource 可通过 api_key 查询参数鉴权。
+// @Tags System
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Param source path string true "扫码登录平台" Enums(netease,qq,qq_wx,kugou,bilibili) example(netease)
+// @Param key query string true "扫码登录 key"
+// @Success 200 {object} service.QREvent "status 事件，data 为状态 JSON"
+// @Failure 400 {object} Response "缺少 key"
+// @Failure 404 {object} Response "未知的扫码登录 key"
+// @Failure 410 {object} Response "扫码登录会话已过期"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Router /api/v1/system/qr_login/{source}/events [get]
+func GetQRLoginEvents(c *gin.Context) {
+	source := strings.TrimSpace(c.Param("source"))
+	key := strings.TrimSpace(c.Query("key"))
+	if key == "" {
+		respondError(c, ErrCodeMissingParameter, "missing qr login key", nil)
+		return
+	}
+	if _, ok := qrSession(c, source, key); !ok {
+		return
+	}
+	events, cancel, err := service.QRM.Subscribe(source, key)
+	if err != nil {
+		respondSourceError(c, source, err, nil)
+		return
+	}
+	defer cancel()
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Accel-Buffering", "no") // 避免反向代理缓冲事件
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
 			}
+			c.SSEvent("status", ev)
+			return !ev.Terminal
+		case <-keepalive.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
 		}
+	})
+}
+
+// GetQRLoginImage 渲染扫码登录二维码
+// @Summary 获取扫码登录二维码图片
+// @Description 在服务端将扫码登录会话的登录链接渲染为二维码图片，可直接用于 <img> 标签(此时可通过 api_key 查询参数鉴权)。只接受本服务创建且未过期的会话。
+// @Tags System
+// @Produce image/png
+// @Produce image/svg+xml
+// @Security ApiKeyAuth
+// @Param source path string true "扫码登录平台" Enums(netease,qq,qq_wx,kugou,bilibili) example(netease)
+// @Param key query string true "扫码登录 key"
+// @Param format query string false "图片格式" Enums(png, svg) default(png)
+// @Param size query int false "图片边长(像素)，范围 64-1024" default(256)
+// @Success 200 {file} binary "二维码图片"
+// @Failure 400 {object} Response "缺少 key 或格式不支持"
+// @Failure 404 {object} Response "未知的扫码登录 key，或会话没有可编码的登录链接"
+// @Failure 410 {object} Response "扫码登录会话已过期"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Router /api/v1/system/qr_login/{source}/image [get]
+func GetQRLoginImage(c *gin.Context) {
+	source := strings.TrimSpace(c.Param("source"))
+	key := strings.TrimSpace(c.Query("key"))
+	if key == "" {
+		respondError(c, ErrCodeMissingParameter, "missing qr login key", nil)
+		return
 	}
-	c.JSON(200, Response{Code: 200, Msg: "success", Data: result})
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "png")))
+	if format != "png" && format != "svg" {
+		respondError(c, ErrCodeBadRequest, "unsupported image format: "+format, nil)
+		return
+	}
+	size := min(max(parsePositiveIntQuery(c, "size", 256), 64), 1024)
+
+	session, ok := qrSession(c, source, key)
+	if !ok {
+		return
+	}
+	content := ""
+	if login := session.LoginSession(); login != nil {
+		content = strings.TrimSpace(login.URL)
+	}
+	if content == "" {
+		respondError(c, ErrCodeNotFound, "qr login session has no login url", nil)
+		return
+	}
+	code, err := qrcode.Encode(content)
+	if err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), nil)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if format == "svg" {
+		c.Data(200, "image/svg+xml", code.SVG(size))
+		return
+	}
+	data, err := code.PNG(size)
+	if err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), nil)
+		return
+	}
+	c.Data(200, "image/png", data)
 }
 
 // ==========================================
@@ -305,7 +408,8 @@ func CheckQRLogin(c *gin.Context) {
 // @Param sources query []string false "指定的音源数组(留空则默认全平台)。例: netease, qq" collectionFormat(multi)
 // @Success 200 {object} Response "成功时返回解析的数据，包含歌曲、歌单或专辑列表"
 // @Failure 400 {object} Response "不支持的链接解析"
-// @Failure 500 {object} Response "解析过程出现错误"
+// @Failure 401 {object} Response "链接解析需要登录 Cookie"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/music/search [get]
 func UnifiedSearch(c *gin.Context) {
 	keyword := strings.TrimSpace(c.Query("q"))
@@ -329,24 +433,29 @@ func UnifiedSearch(c *gin.Context) {
 	var allPlaylists []model.Playlist
 	var allAlbums []model.Playlist
 	var errorMsg string
+	var parseErr error
+	report := newSourceReport()
 
 	if strings.HasPrefix(keyword, "http") {
 		src := service.DetectSource(keyword)
 		if src == "" {
-			c.JSON(400, Response{Code: 400, Msg: "不支持该链接的解析，或无法识别来源"})
+			msg := "不支持该链接的解析，或无法识别来源"
+			respondError(c, ErrCodeSourceUnsupported, msg, legacy(400, Response{Code: 400, Msg: msg}))
 			return
 		}
 
 		parsed := false
-		if parseFn := service.GetParseFunc(src); parseFn != nil {
+		if parseFn := service.GetParseFunc(c.Request.Context(), src); parseFn != nil {
 			if song, err := parseFn(keyword); err == nil {
 				allSongs = append(allSongs, *song)
 				searchType = "song"
 				parsed = true
+			} else {
+				parseErr = service.WrapSourceError(src, err)
 			}
 		}
 		if !parsed {
-			if parsePlaylistFn := service.GetParsePlaylistFunc(src); parsePlaylistFn != nil {
+			if parsePlaylistFn := service.GetParsePlaylistFunc(c.Request.Context(), src); parsePlaylistFn != nil {
 				if playlist, songs, err := parsePlaylistFn(keyword); err == nil {
 					if searchType == "playlist" {
 						allPlaylists = append(allPlaylists, *playlist)
@@ -355,11 +464,13 @@ func UnifiedSearch(c *gin.Context) {
 						searchType = "song"
 					}
 					parsed = true
+				} else {
+					parseErr = service.WrapSourceError(src, err)
 				}
 			}
 		}
 		if !parsed {
-			if parseAlbumFn := service.GetParseAlbumFunc(src); parseAlbumFn != nil {
+			if parseAlbumFn := service.GetParseAlbumFunc(c.Request.Context(), src); parseAlbumFn != nil {
 				if album, songs, err := parseAlbumFn(keyword); err == nil {
 					if searchType == "album" {
 						allAlbums = append(allAlbums, *album)
@@ -368,6 +479,8 @@ func UnifiedSearch(c *gin.Context) {
 						searchType = "song"
 					}
 					parsed = true
+				} else {
+					parseErr = service.WrapSourceError(src, err)
 				}
 			}
 		}
@@ -375,69 +488,93 @@ func UnifiedSearch(c *gin.Context) {
 			errorMsg = fmt.Sprintf("解析失败: 暂不支持 %s 平台的此链接类型或解析出错", src)
 		}
 	} else {
-		var wg sync.WaitGroup
-		var mu sync.Mutex
-
-		for _, src := range sources {
-			wg.Add(1)
-			go func(s string) {
-				defer wg.Done()
-				if searchType == "album" {
-					if fn := service.GetAlbumSearchFunc(s); fn != nil {
-						if res, err := fn(keyword); err == nil {
-							for i := range res {
-								res[i].Source = s
-							}
-							mu.Lock()
-							allAlbums = append(allAlbums, res...)
-							mu.Unlock()
-						}
-					}
-				} else if searchType == "playlist" {
-					if fn := service.GetPlaylistSearchFunc(s); fn != nil {
-						if res, err := fn(keyword); err == nil {
-							for i := range res {
-								res[i].Source = s
-							}
-							mu.Lock()
-							allPlaylists = append(allPlaylists, res...)
-							mu.Unlock()
-						}
-					}
-				} else {
-					if fn := service.GetSearchFunc(s); fn != nil {
-						if res, err := fn(keyword); err == nil {
-							for i := range res {
-								res[i].Source = s
-							}
-							mu.Lock()
-							allSongs = append(allSongs, res...)
-							mu.Unlock()
-						}
-					}
-				}
-			}(src)
-		}
-		wg.Wait()
+		allSongs, allPlaylists, allAlbums = searchSources(c.Request.Context(), keyword, searchType, sources, report)
 	}
 
 	if errorMsg != "" {
-		c.JSON(500, Response{Code: 500, Msg: errorMsg})
+		code := ErrCodeSourceUnsupported
+		if parseErr != nil {
+			code = sourceErrorCode(parseErr)
+		}
+		respondError(c, code, errorMsg, legacy(500, Response{Code: 500, Msg: errorMsg}))
 		return
 	}
 
+	report.write(c)
+	data := gin.H{
+		"type":      searchType,
+		"songs":     allSongs,
+		"playlists": allPlaylists,
+		"albums":    allAlbums,
+	}
+	if !isCompat(c) {
+		data["sources"] = report.statuses()
+	}
 	c.JSON(200, Response{
 		Code: 200,
 		Msg:  "success",
-		Data: gin.H{
-			"type":      searchType,
-			"songs":     allSongs,
-			"playlists": allPlaylists,
-			"albums":    allAlbums,
-		},
+		Data: data,
 	})
 }
 
+// searchSources 在多个平台并发搜索单曲、歌单或专辑，结果按 sources 的顺序合并，熔断中的平台跳过
+func searchSources(ctx context.Context, keyword, searchType string, sources []string, report *sourceReport) (songs []model.Song, playlists, albums []model.Playlist) {
+	type sourceResult struct {
+		songs []model.Song
+		lists []model.Playlist
+	}
+	results := make([]sourceResult, len(sources))
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		if report.skip(src) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, s string) {
+			defer wg.Done()
+			if searchType == "album" || searchType == "playlist" {
+				fn := service.GetPlaylistSearchFunc(ctx, s)
+				if searchType == "album" {
+					fn = service.GetAlbumSearchFunc(ctx, s)
+				}
+				if fn == nil {
+					return
+				}
+				res, err := fn(keyword)
+				report.record(s, err)
+				if err == nil {
+					for j := range res {
+						res[j].Source = s
+					}
+					results[i].lists = res
+				}
+				return
+			}
+			if fn := service.GetSearchFunc(ctx, s); fn != nil {
+				res, err := fn(keyword)
+				report.record(s, err)
+				if err == nil {
+					for j := range res {
+						res[j].Source = s
+					}
+					results[i].songs = res
+				}
+			}
+		}(i, src)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		songs = append(songs, r.songs...)
+		if searchType == "album" {
+			albums = append(albums, r.lists...)
+		} else {
+			playlists = append(playlists, r.lists...)
+		}
+	}
+	return songs, playlists, albums
+}
+
 // ==========================================
 // 单曲相关接口
 // ==========================================
@@ -452,12 +589,20 @@ func UnifiedSearch(c *gin.Context) {
 // @Param name query string false "音乐名称 (用于生成下载文件名)" default(香水有毒) example(香水有毒)
 // @Param artist query string false "歌手名称 (用于生成下载文件名)" default(胡杨林) example(胡杨林)
 // @Success 200 {file} file "直接返回音频二进制流，支持 HTTP Range"
-// @Failure 400 {string} string "参数缺失或非法"
-// @Failure 404 {string} string "找不到音频URL"
-// @Failure 500 {string} string "音频解密失败"
+// @Failure 400 {object} Response "参数缺失或音源不支持"
+// @Failure 401 {object} Response "需要登录 Cookie 或 Cookie 已失效"
+// @Failure 404 {object} Response "找不到音频URL"
+// @Failure 429 {object} Response "上游平台限流"
+// @Failure 500 {object} Response "音频解密失败"
+// @Failure 502 {object} Response "上游平台请求失败"
+// @Failure 504 {object} Response "上游平台超时"
 // @Router /api/v1/music/stream [get]
 func StreamMusic(c *gin.Context) {
-	tempSong := songFromQuery(c)
+	streamSong(c, songFromQuery(c))
+}
+
+// streamSong 代理歌曲音频流，汽水音乐在服务端解密后返回
+func streamSong(c *gin.Context, tempSong *model.Song) {
 	id := tempSong.ID
 	source := tempSong.Source
 	name := tempSong.Name
@@ -472,63 +617,68 @@ func StreamMusic(c *gin.Context) {
 	}
 
 	if id == "" || source == "" {
-		c.String(400, "Missing params")
+		respondError(c, ErrCodeMissingParameter, "missing id or source", legacy(400, "Missing params"))
 		return
 	}
 
 	filename := fmt.Sprintf("%s - %s.mp3", name, artist)
 
 	if source == "soda" {
-		cookie := service.CM.Get("soda")
+		cookie := service.CookieFor(c.Request.Context(), "soda")
 		sodaInst := soda.New(cookie)
 		info, err := sodaInst.GetDownloadInfo(tempSong)
 		if err != nil {
-			c.String(502, "Soda info error")
+			respondSourceError(c, "soda", err, legacy(502, "Soda info error"))
 			return
 		}
-		req, err := buildReq("GET", info.URL, "soda", "")
+		req, err := service.BuildRequest(c.Request.Context(), "GET", info.URL, "soda", "")
 		if err != nil {
-			c.String(502, "Soda request error")
+			respondError(c, ErrCodeUpstream, "soda request error", legacy(502, "Soda request error"))
 			return
 		}
-		resp, err := (&http.Client{}).Do(req)
+		resp, err := service.Stream("soda", req)
 		if err != nil {
-			c.String(502, "Soda stream error")
+			respondSourceError(c, "soda", err, legacy(502, "Soda stream error"))
 			return
 		}
 		defer resp.Body.Close()
 		encryptedData, _ := io.ReadAll(resp.Body)
+		start := time.Now()
 		finalData, err := soda.DecryptAudio(encryptedData, info.PlayAuth)
+		metrics.SodaDecryptDuration.Observe(time.Since(start).Seconds())
 		if err != nil {
-			c.String(500, "Decrypt failed")
+			respondError(c, ErrCodeInternal, "soda decrypt failed", legacy(500, "Decrypt failed"))
 			return
 		}
 		setDownloadHeader(c, filename)
 		http.ServeContent(c.Writer, c.Request, filename, time.Now(), bytes.NewReader(finalData))
+		countStreamedBytes(c, source)
 		return
 	}
 
-	dlFunc := service.GetDownloadFunc(source)
+	dlFunc := service.GetDownloadFunc(c.Request.Context(), source)
 	if dlFunc == nil {
-		c.String(400, "Unknown source")
+		respondError(c, ErrCodeSourceUnsupported, "unsupported source", legacy(400, "Unknown source"))
 		return
 	}
 	downloadUrl, err := dlFunc(tempSong)
-	if err != nil || downloadUrl == "" {
-		c.String(404, "Failed to get URL")
+	if err == nil && downloadUrl == "" {
+		err = service.NewSourceError(source, service.ErrNotFound, nil)
+	}
+	if err != nil {
+		respondSourceError(c, source, err, legacy(404, "Failed to get URL"))
 		return
 	}
 
-	req, err := buildReq("GET", downloadUrl, source, c.GetHeader("Range"))
+	req, err := service.BuildRequest(c.Request.Context(), "GET", downloadUrl, source, c.GetHeader("Range"))
 	if err != nil {
-		c.String(502, "Upstream request error")
+		respondError(c, ErrCodeUpstream, "upstream request error", legacy(502, "Upstream request error"))
 		return
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := service.Stream(source, req)
 	if err != nil {
-		c.String(502, "Upstream stream error")
+		respondSourceError(c, source, err, legacy(502, "Upstream stream error"))
 		return
 	}
 	defer resp.Body.Close()
@@ -542,6 +692,14 @@ func StreamMusic(c *gin.Context) {
 	setDownloadHeader(c, filename)
 	c.Status(resp.StatusCode)
 	io.Copy(c.Writer, resp.Body)
+	countStreamedBytes(c, source)
+}
+
+// countStreamedBytes 记录串流接口实际写给客户端的字节数
+func countStreamedBytes(c *gin.Context, source string) {
+	if n := c.Writer.Size(); n > 0 {
+		metrics.StreamedBytes.WithLabelValues(source).Add(float64(n))
+	}
 }
 
 // InspectMusic 探测音频大小与码率
@@ -553,6 +711,9 @@ func StreamMusic(c *gin.Context) {
 // @Param source query string true "音乐来源平台" default(netease) example(netease)
 // @Param duration query string false "音乐时长(秒)，提供可精确预估码率(kbps)" default(290) example(290)
 // @Success 200 {object} Response "包含有效状态、真实URL、文件大小和码率等探测信息"
+// @Failure 400 {object} Response "音源不支持"
+// @Failure 404 {object} Response "找不到音频URL"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/music/inspect [get]
 func InspectMusic(c *gin.Context) {
 	song := songFromQuery(c)
@@ -562,38 +723,40 @@ func InspectMusic(c *gin.Context) {
 	var urlStr string
 	var err error
 
+	invalid := legacy(200, gin.H{"valid": false})
 	if src == "soda" {
-		cookie := service.CM.Get("soda")
+		cookie := service.CookieFor(c.Request.Context(), "soda")
 		sodaInst := soda.New(cookie)
 		info, sErr := sodaInst.GetDownloadInfo(song)
 		if sErr != nil {
-			c.JSON(200, gin.H{"valid": false})
+			respondSourceError(c, src, sErr, invalid)
 			return
 		}
 		urlStr = info.URL
 	} else {
-		fn := service.GetDownloadFunc(src)
+		fn := service.GetDownloadFunc(c.Request.Context(), src)
 		if fn == nil {
-			c.JSON(200, gin.H{"valid": false})
+			respondError(c, ErrCodeSourceUnsupported, "unsupported source", invalid)
 			return
 		}
 		urlStr, err = fn(song)
-		if err != nil || urlStr == "" {
-			c.JSON(200, gin.H{"valid": false})
+		if err == nil && urlStr == "" {
+			err = service.NewSourceError(src, service.ErrNotFound, nil)
+		}
+		if err != nil {
+			respondSourceError(c, src, err, invalid)
 			return
 		}
 	}
 
-	req, _ := buildReq("GET", urlStr, src, "bytes=0-1")
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := service.ProbeURL(c.Request.Context(), src, urlStr)
 
 	valid := false
 	var size int64 = 0
 
 	if err == nil {
 		defer resp.Body.Close()
-		if resp.StatusCode == 200 || resp.StatusCode == 206 {
+		if service.Playable(resp) {
 			valid = true
 			cr := resp.Header.Get("Content-Range")
 			if parts := strings.Split(cr, "/"); len(parts) == 2 {
@@ -645,96 +808,31 @@ func SwitchSource(c *gin.Context) {
 	origDuration, _ := strconv.Atoi(durationStr)
 
 	if name == "" {
-		c.JSON(400, gin.H{"error": "missing name"})
+		respondError(c, ErrCodeMissingParameter, "missing name", legacy(400, gin.H{"error": "missing name"}))
 		return
 	}
 
-	keyword := name
-	if artist != "" {
-		keyword = name + " " + artist
-	}
-
 	var sources []string
 	if target != "" {
 		sources = []string{target}
 	} else {
-		sources = []string{"netease", "qq", "kugou", "kuwo", "migu", "bilibili"}
-	}
-
-	type candidate struct {
-		song    model.Song
-		score   float64
-		durDiff int
-	}
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var candidates []candidate
-
-	for _, src := range sources {
-		if src == "" || src == current || src == "soda" || src == "fivesing" {
-			continue
-		}
-		fn := service.GetSearchFunc(src)
-		if fn == nil {
-			continue
-		}
-
-		wg.Add(1)
-		go func(s string) {
-			defer wg.Done()
-			res, err := fn(keyword)
-			if (err != nil || len(res) == 0) && artist != "" {
-				res, _ = fn(name)
-			}
-			if len(res) == 0 {
-				return
-			}
-
-			limit := len(res)
-			if limit > 8 {
-				limit = 8
-			}
-
-			for i := 0; i < limit; i++ {
-				cand := res[i]
-				cand.Source = s
-				score := calcSongSimilarity(name, artist, cand.Name, cand.Artist)
-				if score <= 0 {
-					continue
-				}
-
-				durDiff := 0
-				if origDuration > 0 && cand.Duration > 0 {
-					durDiff = intAbs(origDuration - cand.Duration)
-					if !isDurationClose(origDuration, cand.Duration) {
-						continue
-					}
-				}
-
-				mu.Lock()
-				candidates = append(candidates, candidate{song: cand, score: score, durDiff: durDiff})
-				mu.Unlock()
-			}
-		}(src)
+		sources = service.GetMatchSourceNames()
 	}
-	wg.Wait()
 
+	report := newSourceReport()
+	candidates := searchSongCandidates(c.Request.Context(), name, artist, origDuration, sources, report, func(s string) bool {
+		return s == current || s == "soda" || s == "fivesing"
+	})
+	report.write(c)
 	if len(candidates) == 0 {
-		c.JSON(404, gin.H{"error": "no match"})
+		respondError(c, ErrCodeNotFound, "no match", legacy(404, gin.H{"error": "no match"}))
 		return
 	}
 
-	sort.SliceStable(candidates, func(i, j int) bool {
-		if candidates[i].score == candidates[j].score {
-			return candidates[i].durDiff < candidates[j].durDiff
-		}
-		return candidates[i].score > candidates[j].score
-	})
-
 	var selected *model.Song
 	var selectedScore float64
 	for _, cand := range candidates {
-		if validatePlayable(&cand.song) {
+		if validatePlayable(c.Request.Context(), &cand.song) {
 			tmp := cand.song
 			selected = &tmp
 			selectedScore = cand.score
@@ -742,7 +840,7 @@ func SwitchSource(c *gin.Context) {
 		}
 	}
 	if selected == nil {
-		c.JSON(404, gin.H{"error": "no playable match"})
+		respondError(c, ErrCodeNotFound, "no playable match", legacy(404, gin.H{"error": "no playable match"}))
 		return
 	}
 
@@ -768,19 +866,24 @@ func SwitchSource(c *gin.Context) {
 // @Param source query string true "平台源" default(netease) example(netease)
 // @Success 200 {object} Response "直接返回带有 url 的数据实体"
 // @Failure 400 {object} Response "源不支持"
-// @Failure 500 {object} Response "链接抓取失败"
+// @Failure 401 {object} Response "需要登录 Cookie"
+// @Failure 404 {object} Response "无可用音频链接"
+// @Failure 502 {object} Response "链接抓取失败"
 // @Router /api/v1/music/url [get]
 func GetMusicUrl(c *gin.Context) {
 	song := songFromQuery(c)
 	src := song.Source
-	fn := service.GetDownloadFunc(src)
+	fn := service.GetDownloadFunc(c.Request.Context(), src)
 	if fn == nil {
-		c.JSON(400, Response{Code: 400, Msg: "不支持的源"})
+		respondError(c, ErrCodeSourceUnsupported, "不支持的源", nil)
 		return
 	}
 	urlStr, err := fn(song)
+	if err == nil && urlStr == "" {
+		err = service.NewSourceError(src, service.ErrNotFound, nil)
+	}
 	if err != nil {
-		c.JSON(500, Response{Code: 500, Msg: err.Error()})
+		respondSourceError(c, src, err, nil)
 		return
 	}
 	c.JSON(200, Response{Code: 200, Msg: "success", Data: gin.H{"url": urlStr}})
@@ -792,57 +895,96 @@ func GetMusicUrl(c *gin.Context) {
 
 // GetLyric 获取 JSON 格式歌词
 // @Summary 获取 JSON 格式歌词
-// @Description 抓取对应歌曲的完整 LRC 歌词文本，以 JSON 格式返回。
+// @Description 抓取对应歌曲的完整 LRC 歌词文本，以 JSON 格式返回。原平台无歌词或不支持歌词时，会按歌名、歌手和时长在其它平台查找同一首歌的歌词，并在 `source` 中返回实际提供歌词的平台。
 // @Tags Music
 // @Produce json
 // @Param id query string true "音乐 ID" default(240479) example(240479)
 // @Param source query string true "平台" default(netease) example(netease)
-// @Success 200 {object} Response "包含 lyric 字符串属性的数据对象"
-// @Failure 400 {object} Response "对应平台未实现歌词抓取"
+// @Param name query string false "歌曲名称 (跨平台回退查找时必填)" default(香水有毒) example(香水有毒)
+// @Param artist query string false "歌手名称" default(胡杨林) example(胡杨林)
+// @Param duration query int false "歌曲时长(秒)，提高回退匹配准确度" example(290)
+// @Param fallback query bool false "原平台无歌词时是否跨平台回退查找" default(true)
+// @Param offset_ms query int false "歌词时间偏移(毫秒)，正数表示歌词延后；留空则使用已保存的该曲校正值" example(-500)
+// @Success 200 {object} Response "包含 lyric、source、fallback、offset_ms 属性的数据对象"
+// @Failure 400 {object} Response "对应平台未实现歌词抓取或 offset_ms 非法"
+// @Failure 401 {object} Response "需要登录 Cookie 或 Cookie 已失效"
+// @Failure 404 {object} Response "各平台均无歌词"
+// @Failure 429 {object} Response "上游平台限流"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/music/lyric [get]
 func GetLyric(c *gin.Context) {
 	song := songFromQuery(c)
 	src := song.Source
-	fn := service.GetLyricFunc(src)
-	if fn == nil {
-		c.JSON(400, Response{Code: 400, Msg: "无歌词支持"})
+	offset, ok := lyricOffsetFromQuery(c, song)
+	if !ok {
+		respondError(c, ErrCodeBadRequest, "offset_ms 参数非法", nil)
+		return
+	}
+	res := fetchLyric(c.Request.Context(), song, parseBoolQuery(c, "fallback", true))
+	if res.unsupported {
+		respondError(c, ErrCodeSourceUnsupported, "无歌词支持", nil)
+		return
+	}
+	if res.err != nil {
+		respondSourceError(c, src, res.err, nil)
 		return
 	}
-	lrc, _ := fn(song)
-	c.JSON(200, Response{Code: 200, Msg: "success", Data: gin.H{"lyric": lrc}})
+	lrc := res.lyric
+	if offset != 0 {
+		lrc = service.ShiftLyric(lrc, offset)
+	}
+	data := gin.H{"lyric": lrc, "source": res.song.Source, "fallback": res.fallback, "offset_ms": offset}
+	if res.fallback {
+		data["song"] = res.song
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: data})
 }
 
 // GetLyricText 返回纯文本歌词
 // @Summary 返回纯文本歌词 (旧版兼容)
-// @Description 直接返回 `text/plain` 格式的纯歌词内容。若拉取失败，返回默认占位符提示。
+// @Description 直接返回 `text/plain` 格式的纯歌词内容。原平台无歌词时跨平台回退查找，实际来源写入 `X-Lyric-Source` 响应头。若仍拉取失败，返回默认占位符提示。
 // @Tags Music (Compat)
 // @Produce text/plain
 // @Param id query string true "音乐 ID" default(240479) example(240479)
 // @Param source query string true "平台" default(netease) example(netease)
+// @Param name query string false "歌曲名称 (跨平台回退查找时必填)" default(香水有毒) example(香水有毒)
+// @Param artist query string false "歌手名称" default(胡杨林) example(胡杨林)
+// @Param duration query int false "歌曲时长(秒)" example(290)
+// @Param fallback query bool false "原平台无歌词时是否跨平台回退查找" default(true)
+// @Param offset_ms query int false "歌词时间偏移(毫秒)，正数表示歌词延后；留空则使用已保存的该曲校正值" example(-500)
 // @Success 200 {string} string "LRC 文本"
 // @Router /music/lyric [get]
 func GetLyricText(c *gin.Context) {
 	song := songFromQuery(c)
-	src := song.Source
-	if fn := service.GetLyricFunc(src); fn != nil {
-		if lrc, _ := fn(song); lrc != "" {
-			c.String(200, lrc)
-			return
+	if res := fetchLyric(c.Request.Context(), song, parseBoolQuery(c, "fallback", true)); res.lyric != "" {
+		lrc := res.lyric
+		if offset, ok := lyricOffsetFromQuery(c, song); ok && offset != 0 {
+			lrc = service.ShiftLyric(lrc, offset)
 		}
+		setLyricSourceHeader(c, res)
+		c.String(200, lrc)
+		return
 	}
 	c.String(200, "[00:00.00] 暂无歌词")
 }
 
-// DownloadLyricFile 下载 LRC 文件
-// @Summary 下载 LRC 歌词文件
-// @Description 作为附件直接下载 `.lrc` 后缀的歌词文件到本地。
+// DownloadLyricFile 下载歌词文件
+// @Summary 下载歌词文件
+// @Description 作为附件下载歌词文件，支持将 LRC 转换为 SRT、WebVTT、ASS (逐字歌词生成卡拉 OK 标签) 与 TTML 格式。
 // @Tags Music
 // @Produce application/octet-stream
 // @Param id query string true "音乐 ID" default(240479) example(240479)
 // @Param source query string true "平台" default(netease) example(netease)
 // @Param name query string false "音乐名称 (生成保存文件名)" default(香水有毒) example(香水有毒)
 // @Param artist query string false "歌手名称 (生成保存文件名)" default(胡杨林) example(胡杨林)
-// @Success 200 {file} file "纯文本文件流"
+// @Param duration query int false "歌曲时长(秒)，用于推算最后一行歌词的结束时间" example(290)
+// @Param format query string false "导出格式" Enums(lrc,srt,vtt,ass,ttml) default(lrc)
+// @Param fallback query bool false "原平台无歌词时是否跨平台回退查找" default(true)
+// @Param offset_ms query int false "歌词时间偏移(毫秒)，正数表示歌词延后；留空则使用已保存的该曲校正值" example(-500)
+// @Success 200 {file} file "歌词文件流"
+// @Failure 400 {object} Response "不支持的导出格式、平台或 offset_ms 非法"
+// @Failure 404 {object} Response "各平台均无歌词"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/music/lyric/file [get]
 func DownloadLyricFile(c *gin.Context) {
 	song := songFromQuery(c)
@@ -856,19 +998,121 @@ func DownloadLyricFile(c *gin.Context) {
 		artist = "Unknown"
 	}
 
-	fn := service.GetLyricFunc(src)
-	if fn == nil {
-		c.String(404, "No support")
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", service.LyricFormatLRC)))
+	ext, mime, ok := service.GetLyricFormatInfo(format)
+	if !ok {
+		respondError(c, ErrCodeBadRequest, "unsupported lyric format", legacy(400, "Unsupported format"))
+		return
+	}
+
+	offset, ok := lyricOffsetFromQuery(c, song)
+	if !ok {
+		respondError(c, ErrCodeBadRequest, "invalid offset_ms", legacy(400, "Invalid offset_ms"))
+		return
+	}
+	res := fetchLyric(c.Request.Context(), song, parseBoolQuery(c, "fallback", true))
+	if res.unsupported {
+		respondError(c, ErrCodeSourceUnsupported, "unsupported source", legacy(404, "No support"))
+		return
+	}
+	if res.err != nil {
+		respondSourceError(c, src, res.err, legacy(404, "Lyric not found"))
+		return
+	}
+	lrc := res.lyric
+	if offset != 0 {
+		lrc = service.ShiftLyric(lrc, offset)
+	}
+
+	content, err := service.ConvertLyric(lrc, format, song.Duration)
+	if err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), legacy(500, "Convert failed"))
+		return
+	}
+
+	setLyricSourceHeader(c, res)
+	setDownloadHeader(c, fmt.Sprintf("%s - %s.%s", name, artist, ext))
+	if format == service.LyricFormatLRC {
+		c.String(200, content)
 		return
 	}
-	lrc, _ := fn(song)
-	if lrc == "" {
-		c.String(404, "Lyric not found")
+	c.Data(200, mime, []byte(content))
+}
+
+type lyricOffsetRequest struct {
+	Source   string `json:"source" example:"netease"`
+	ID       string `json:"id" example:"240479"`
+	OffsetMs int    `json:"offset_ms" example:"-500"`
+}
+
+// GetLyricOffset 获取歌词偏移校正
+// @Summary 获取已保存的歌词偏移校正
+// @Description 返回指定歌曲已保存的歌词时间偏移(毫秒)，未保存时为 0。
+// @Tags Music
+// @Produce json
+// @Param id query string true "音乐 ID" default(240479) example(240479)
+// @Param source query string true "平台" default(netease) example(netease)
+// @Success 200 {object} Response "包含 offset_ms 的数据对象"
+// @Failure 400 {object} Response "参数缺失"
+// @Router /api/v1/music/lyric/offset [get]
+func GetLyricOffset(c *gin.Context) {
+	id, src := strings.TrimSpace(c.Query("id")), strings.TrimSpace(c.Query("source"))
+	if id == "" || src == "" {
+		respondError(c, ErrCodeMissingParameter, "参数缺失", nil)
 		return
 	}
+	offset, saved := service.LOM.Get(src, id)
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: gin.H{
+		"source":    src,
+		"id":        id,
+		"offset_ms": offset,
+		"saved":     saved,
+	}})
+}
 
-	setDownloadHeader(c, fmt.Sprintf("%s - %s.lrc", name, artist))
-	c.String(200, lrc)
+// SetLyricOffset 保存歌词偏移校正
+// @Summary 保存歌词偏移校正
+// @Description 保存指定歌曲的歌词时间偏移(毫秒，正数表示歌词延后)，之后未显式传入 offset_ms 的歌词请求都会自动应用。传 0 表示清除。需要 client 角色。
+// @Tags Music
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param offset body lyricOffsetRequest true "歌词偏移校正"
+// @Success 200 {object} Response "操作成功"
+// @Failure 400 {object} Response "参数缺失、平台不支持、ID 过长或偏移超出范围"
+// @Failure 401 {object} Response "缺少或无效的 API Key"
+// @Failure 500 {object} Response "保存失败"
+// @Router /api/v1/music/lyric/offset [post]
+func SetLyricOffset(c *gin.Context) {
+	var req lyricOffsetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeBadRequest, "Invalid JSON", nil)
+		return
+	}
+	req.Source = strings.TrimSpace(req.Source)
+	req.ID = strings.TrimSpace(req.ID)
+	if req.Source == "" || req.ID == "" {
+		respondError(c, ErrCodeMissingParameter, "参数缺失", nil)
+		return
+	}
+	if !slices.Contains(service.GetAllSourceNames(), req.Source) {
+		respondError(c, ErrCodeSourceUnsupported, "不支持的源", nil)
+		return
+	}
+	if len(req.ID) > service.MaxLyricOffsetIDLen {
+		respondError(c, ErrCodeBadRequest, "id 过长", nil)
+		return
+	}
+	if intAbs(req.OffsetMs) > service.MaxLyricOffsetMs {
+		respondError(c, ErrCodeBadRequest, "offset_ms 超出范围", nil)
+		return
+	}
+	service.LOM.Set(req.Source, req.ID, req.OffsetMs)
+	if err := service.LOM.Save(); err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: req})
 }
 
 // ProxyCover 代理并下载封面防盗链
@@ -880,17 +1124,23 @@ func DownloadLyricFile(c *gin.Context) {
 // @Param name query string false "歌曲名(用于生成下载文件名)" default(香水有毒) example(香水有毒)
 // @Param artist query string false "歌手名(用于生成下载文件名)" default(胡杨林) example(胡杨林)
 // @Success 200 {file} file "原封不动的图片流"
+// @Failure 400 {object} Response "缺少 url"
+// @Failure 502 {object} Response "封面拉取失败"
 // @Router /api/v1/music/cover [get]
 func ProxyCover(c *gin.Context) {
 	u := c.Query("url")
 	if u == "" {
+		respondError(c, ErrCodeMissingParameter, "missing url", legacy(200, ""))
 		return
 	}
-	resp, err := utils.Get(u, utils.WithHeader("User-Agent", UA_Common))
-	if err == nil {
-		setDownloadHeader(c, fmt.Sprintf("%s - %s.jpg", c.Query("name"), c.Query("artist")))
-		c.Data(200, "image/jpeg", resp)
+	resp, err := service.FetchCover(c.Request.Context(), u)
+	// 兼容路由沿用旧行为，上游的非 200 响应体也原样返回
+	if err != nil && (resp == nil || !isCompat(c)) {
+		respondSourceError(c, "", err, legacy(200, ""))
+		return
 	}
+	setDownloadHeader(c, fmt.Sprintf("%s - %s.jpg", c.Query("name"), c.Query("artist")))
+	c.Data(200, "image/jpeg", resp)
 }
 
 // ==========================================
@@ -906,21 +1156,24 @@ func ProxyCover(c *gin.Context) {
 // @Param source query string true "歌单所属平台" default(netease) example(netease)
 // @Success 200 {object} Response "成功的数组列表"
 // @Failure 400 {object} Response "源不支持"
+// @Failure 404 {object} Response "歌单不存在"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/playlist/detail [get]
 func GetPlaylistDetail(c *gin.Context) {
 	id, src := c.Query("id"), c.Query("source")
 	if id == "" || src == "" {
-		c.JSON(400, Response{Code: 400, Msg: "参数缺失"})
+		respondError(c, ErrCodeMissingParameter, "参数缺失", legacy(400, Response{Code: 400, Msg: "参数缺失"}))
 		return
 	}
-	fn := service.GetPlaylistDetailFunc(src)
+	fn := service.GetPlaylistDetailFunc(c.Request.Context(), src)
 	if fn == nil {
-		c.JSON(400, Response{Code: 400, Msg: "不支持获取该源的歌单"})
+		msg := "不支持获取该源的歌单"
+		respondError(c, ErrCodeSourceUnsupported, msg, legacy(400, Response{Code: 400, Msg: msg}))
 		return
 	}
 	songs, err := fn(id)
 	if err != nil {
-		c.JSON(500, Response{Code: 500, Msg: err.Error()})
+		respondSourceError(c, src, err, legacy(500, Response{Code: 500, Msg: err.Error()}))
 		return
 	}
 	for i := range songs {
@@ -946,16 +1199,18 @@ func GetRecommendPlaylists(c *gin.Context) {
 	var allPlaylists []model.Playlist
 	var wg sync.WaitGroup
 	var mu sync.Mutex
+	report := newSourceReport()
 
 	for _, src := range sources {
-		fn := service.GetRecommendFunc(src)
-		if fn == nil {
+		fn := service.GetRecommendFunc(c.Request.Context(), src)
+		if fn == nil || report.skip(src) {
 			continue
 		}
 		wg.Add(1)
 		go func(s string) {
 			defer wg.Done()
 			res, err := fn()
+			report.record(s, err)
 			if err == nil && len(res) > 0 {
 				for i := range res {
 					res[i].Source = s
@@ -967,6 +1222,7 @@ func GetRecommendPlaylists(c *gin.Context) {
 		}(src)
 	}
 	wg.Wait()
+	report.write(c)
 	c.JSON(200, Response{Code: 200, Msg: "success", Data: allPlaylists})
 }
 
@@ -979,21 +1235,24 @@ func GetRecommendPlaylists(c *gin.Context) {
 // @Param source query string true "专辑所属平台" Enums(netease,qq,kugou,kuwo,migu,jamendo,joox,qianqian,soda) default(netease)
 // @Success 200 {object} Response "专辑歌曲列表"
 // @Failure 400 {object} Response "源不支持或参数缺失"
+// @Failure 404 {object} Response "专辑不存在"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/album/detail [get]
 func GetAlbumDetail(c *gin.Context) {
 	id, src := strings.TrimSpace(c.Query("id")), strings.TrimSpace(c.Query("source"))
 	if id == "" || src == "" {
-		c.JSON(400, Response{Code: 400, Msg: "参数缺失"})
+		respondError(c, ErrCodeMissingParameter, "参数缺失", legacy(400, Response{Code: 400, Msg: "参数缺失"}))
 		return
 	}
-	fn := service.GetAlbumDetailFunc(src)
+	fn := service.GetAlbumDetailFunc(c.Request.Context(), src)
 	if fn == nil {
-		c.JSON(400, Response{Code: 400, Msg: "不支持获取该源的专辑"})
+		msg := "不支持获取该源的专辑"
+		respondError(c, ErrCodeSourceUnsupported, msg, legacy(400, Response{Code: 400, Msg: msg}))
 		return
 	}
 	songs, err := fn(id)
 	if err != nil {
-		c.JSON(500, Response{Code: 500, Msg: err.Error()})
+		respondSourceError(c, src, err, legacy(500, Response{Code: 500, Msg: err.Error()}))
 		return
 	}
 	for i := range songs {
@@ -1020,6 +1279,7 @@ func GetPlaylistCategories(c *gin.Context) {
 		Name       string                   `json:"name"`
 		Categories []model.PlaylistCategory `json:"categories"`
 		Error      string                   `json:"error,omitempty"`
+		ErrorCode  string                   `json:"error_code,omitempty"`
 	}
 	results := make([]categorySource, 0, len(sources))
 	for _, src := range sources {
@@ -1028,15 +1288,22 @@ func GetPlaylistCategories(c *gin.Context) {
 			continue
 		}
 		item := categorySource{Source: src, Name: service.GetSourceDescription(src)}
-		fn := service.GetPlaylistCategoriesFunc(src)
+		fn := service.GetPlaylistCategoriesFunc(c.Request.Context(), src)
 		if fn == nil {
 			item.Error = "unsupported source"
+			if !isCompat(c) {
+				item.ErrorCode = ErrCodeSourceUnsupported
+			}
 			results = append(results, item)
 			continue
 		}
 		categories, err := fn()
-		if err != nil {
+		if err != nil && isCompat(c) {
+			item.Error = err.Error()
+		} else if err != nil {
+			err = service.WrapSourceError(src, err)
 			item.Error = err.Error()
+			item.ErrorCode = sourceErrorCode(err)
 		} else {
 			item.Categories = categories
 		}
@@ -1056,6 +1323,7 @@ func GetPlaylistCategories(c *gin.Context) {
 // @Param limit query int false "每页数量" default(30)
 // @Success 200 {object} Response "分类歌单列表"
 // @Failure 400 {object} Response "源不支持或参数缺失"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/playlist/category [get]
 func GetCategoryPlaylists(c *gin.Context) {
 	src := strings.TrimSpace(c.Query("source"))
@@ -1066,17 +1334,18 @@ func GetCategoryPlaylists(c *gin.Context) {
 	page := parsePositiveIntQuery(c, "page", 1)
 	limit := parsePositiveIntQuery(c, "limit", 30)
 	if src == "" || categoryID == "" {
-		c.JSON(400, Response{Code: 400, Msg: "参数缺失"})
+		respondError(c, ErrCodeMissingParameter, "参数缺失", legacy(400, Response{Code: 400, Msg: "参数缺失"}))
 		return
 	}
-	fn := service.GetCategoryPlaylistsFunc(src)
+	fn := service.GetCategoryPlaylistsFunc(c.Request.Context(), src)
 	if fn == nil {
-		c.JSON(400, Response{Code: 400, Msg: "不支持获取该源的分类歌单"})
+		msg := "不支持获取该源的分类歌单"
+		respondError(c, ErrCodeSourceUnsupported, msg, legacy(400, Response{Code: 400, Msg: msg}))
 		return
 	}
 	playlists, err := fn(categoryID, page, limit)
 	if err != nil {
-		c.JSON(500, Response{Code: 500, Msg: err.Error()})
+		respondSourceError(c, src, err, legacy(500, Response{Code: 500, Msg: err.Error()}))
 		return
 	}
 	for i := range playlists {
@@ -1101,18 +1370,21 @@ func GetCategoryPlaylists(c *gin.Context) {
 // @Param limit query int false "每页数量" default(30)
 // @Success 200 {object} Response "个人歌单列表"
 // @Failure 400 {object} Response "源不支持或参数缺失"
+// @Failure 401 {object} Response "需要登录 Cookie 或 Cookie 已失效"
+// @Failure 502 {object} Response "上游平台请求失败"
 // @Router /api/v1/playlist/user [get]
 func GetUserPlaylists(c *gin.Context) {
 	src := strings.TrimSpace(c.Query("source"))
 	page := parsePositiveIntQuery(c, "page", 1)
 	limit := parsePositiveIntQuery(c, "limit", 30)
 	if src == "" {
-		c.JSON(400, Response{Code: 400, Msg: "参数缺失"})
+		respondError(c, ErrCodeMissingParameter, "参数缺失", legacy(400, Response{Code: 400, Msg: "参数缺失"}))
 		return
 	}
-	fn := service.GetUserPlaylistsFunc(src)
+	fn := service.GetUserPlaylistsFunc(c.Request.Context(), src)
 	if fn == nil {
-		c.JSON(400, Response{Code: 400, Msg: "不支持获取该源的个人歌单"})
+		msg := "不支持获取该源的个人歌单"
+		respondError(c, ErrCodeSourceUnsupported, msg, legacy(400, Response{Code: 400, Msg: msg}))
 		return
 	}
 	playlists, err := fn(page, limit)
@@ -1121,7 +1393,7 @@ func GetUserPlaylists(c *gin.Context) {
 		if strings.Contains(strings.ToLower(err.Error()), "require cookie") {
 			status = 401
 		}
-		c.JSON(status, Response{Code: status, Msg: err.Error()})
+		respondSourceError(c, src, err, legacy(status, Response{Code: status, Msg: err.Error()}))
 		return
 	}
 	for i := range playlists {
@@ -1135,18 +1407,192 @@ func GetUserPlaylists(c *gin.Context) {
 	}})
 }
 
+// ==========================================
+// 歌词查询辅助函数
+// ==========================================
+
+// 跨平台回退查找歌词时最多尝试拉取歌词的候选数，参与搜索的平台与 SwitchSource 相同
+const lyricFallbackMaxTries = 5
+
+// lyricFallbackMinScore 回退候选的最低相似度，同名但歌手不同的歌曲得分为 0.7，不会被采用
+const lyricFallbackMinScore = 0.8
+
+type lyricResult struct {
+	lyric       string
+	song        *model.Song // 实际提供歌词的歌曲
+	fallback    bool
+	unsupported bool  // 原平台不支持歌词且回退未找到
+	err         error // 未取到歌词时的原因
+}
+
+// fetchLyric 获取歌曲歌词；原平台无歌词或不支持歌词且允许回退时，
+// 按 SwitchSource 相同的歌名/歌手/时长匹配规则在其它平台查找
+func fetchLyric(ctx context.Context, song *model.Song, fallback bool) lyricResult {
+	// 原平台没有报错但歌词为空时视为歌词不存在
+	primaryErr := service.NewSourceError(song.Source, service.ErrNotFound, nil)
+	fn := service.GetLyricFunc(ctx, song.Source)
+	supported := fn != nil
+	if supported {
+		lrc, err := fn(song)
+		if strings.TrimSpace(lrc) != "" {
+			return lyricResult{lyric: lrc, song: song}
+		}
+		if err != nil {
+			primaryErr = service.WrapSourceError(song.Source, err)
+		}
+	}
+	if !fallback || song.Name == "" {
+		return lyricResult{song: song, unsupported: !supported, err: primaryErr}
+	}
+
+	candidates := searchSongCandidates(ctx, song.Name, song.Artist, song.Duration, service.GetMatchSourceNames(), nil, func(s string) bool {
+		return s == song.Source
+	})
+	var lastErr error
+	for i := 0; i < len(candidates) && i < lyricFallbackMaxTries; i++ {
+		// 候选按相似度降序排列，低于阈值的都不是同一首歌
+		if candidates[i].score < lyricFallbackMinScore {
+			break
+		}
+		cand := candidates[i].song
+		fn := service.GetLyricFunc(ctx, cand.Source)
+		if fn == nil {
+			continue
+		}
+		lrc, err := fn(&cand)
+		if strings.TrimSpace(lrc) != "" {
+			return lyricResult{lyric: lrc, song: &cand, fallback: true}
+		}
+		if err != nil {
+			lastErr = service.WrapSourceError(cand.Source, err)
+			slog.DebugContext(ctx, "lyric fallback failed", "source", cand.Source, "id", cand.ID, "error", lastErr)
+		}
+	}
+	return lyricResult{song: song, unsupported: !supported, err: withFallbackError(primaryErr, lastErr)}
+}
+
+// withFallbackError 在原平台错误后附上最后一个回退候选的失败原因，错误类型仍以原平台为准
+func withFallbackError(primary, fallback error) error {
+	var se *service.SourceError
+	if fallback == nil || !errors.As(primary, &se) {
+		return primary
+	}
+	cause := se.Err
+	if cause == nil {
+		cause = se.Kind
+	}
+	return &service.SourceError{Source: se.Source, Kind: se.Kind, Err: fmt.Errorf("%w; fallback: %v", cause, fallback)}
+}
+
+// lyricOffsetFromQuery 解析 offset_ms 参数；未传入时使用该曲已保存的校正值，ok 为 false 表示参数非法
+func lyricOffsetFromQuery(c *gin.Context, song *model.Song) (offset int, ok bool) {
+	raw, exists := c.GetQuery("offset_ms")
+	if raw = strings.TrimSpace(raw); !exists || raw == "" {
+		offset, _ = service.LOM.Get(song.Source, song.ID)
+		return offset, true
+	}
+	offset, err := strconv.Atoi(raw)
+	if err != nil || intAbs(offset) > service.MaxLyricOffsetMs {
+		return 0, false
+	}
+	return offset, true
+}
+
+func setLyricSourceHeader(c *gin.Context, res lyricResult) {
+	c.Header("X-Lyric-Source", res.song.Source)
+	if res.fallback {
+		c.Header("X-Lyric-Fallback", "true")
+	}
+}
+
 // ==========================================
 // 算法与校验辅助函数 (用于 SwitchSource)
 // ==========================================
 
-func validatePlayable(song *model.Song) bool {
+type songCandidate struct {
+	song    model.Song
+	score   float64
+	durDiff int
+}
+
+// searchSongCandidates 在多个平台并发搜索同名歌曲，按相似度与时长差排序返回候选
+func searchSongCandidates(ctx context.Context, name, artist string, origDuration int, sources []string, report *sourceReport, skip func(string) bool) []songCandidate {
+	keyword := name
+	if artist != "" {
+		keyword = name + " " + artist
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var candidates []songCandidate
+
+	for _, src := range sources {
+		if src == "" || (skip != nil && skip(src)) {
+			continue
+		}
+		fn := service.GetSearchFunc(ctx, src)
+		if fn == nil || report.skip(src) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(s string) {
+			defer wg.Done()
+			res, err := fn(keyword)
+			if (err != nil || len(res) == 0) && artist != "" && !errors.Is(err, service.ErrCircuitOpen) {
+				res, err = fn(name)
+			}
+			report.record(s, err)
+			if len(res) == 0 {
+				return
+			}
+
+			limit := len(res)
+			if limit > 8 {
+				limit = 8
+			}
+
+			for i := 0; i < limit; i++ {
+				cand := res[i]
+				cand.Source = s
+				score := calcSongSimilarity(name, artist, cand.Name, cand.Artist)
+				if score <= 0 {
+					continue
+				}
+
+				durDiff := 0
+				if origDuration > 0 && cand.Duration > 0 {
+					durDiff = intAbs(origDuration - cand.Duration)
+					if !isDurationClose(origDuration, cand.Duration) {
+						continue
+					}
+				}
+
+				mu.Lock()
+				candidates = append(candidates, songCandidate{song: cand, score: score, durDiff: durDiff})
+				mu.Unlock()
+			}
+		}(src)
+	}
+	wg.Wait()
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score == candidates[j].score {
+			return candidates[i].durDiff < candidates[j].durDiff
+		}
+		return candidates[i].score > candidates[j].score
+	})
+	return candidates
+}
+
+func validatePlayable(ctx context.Context, song *model.Song) bool {
 	if song == nil || song.ID == "" || song.Source == "" {
 		return false
 	}
 	if song.Source == "soda" || song.Source == "fivesing" {
 		return false
 	}
-	fn := service.GetDownloadFunc(song.Source)
+	fn := service.GetDownloadFunc(ctx, song.Source)
 	if fn == nil {
 		return false
 	}
@@ -1154,17 +1600,12 @@ func validatePlayable(song *model.Song) bool {
 	if err != nil || urlStr == "" {
 		return false
 	}
-	req, err := buildReq("GET", urlStr, song.Source, "bytes=0-1")
-	if err != nil {
-		return false
-	}
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := service.ProbeURL(ctx, song.Source, urlStr)
 	if err != nil {
 		return false
 	}
 	defer resp.Body.Close()
-	return resp.StatusCode == 200 || resp.StatusCode == 206
+	return service.Playable(resp)
 }
 
 func intAbs(x int) int {
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+// 超过该时长未访问的客户端限流状态会被清理
+const limiterIdleTTL = 10 * time.Minute
+
+type clientLimit struct {
+	bucket   *service.TokenBucket
+	inflight int
+	lastSeen time.Time
+}
+
+type routeLimiter struct {
+	rule      config.RateLimitRule
+	mu        sync.Mutex
+	clients   map[string]*clientLimit
+	lastSweep time.Time
+}
+
+// acquire 占用一个并发名额并消耗一个令牌，失败时返回建议的重试等待时间
+func (l *routeLimiter) acquire(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastSweep) > limiterIdleTTL {
+		for k, cl := range l.clients {
+			if cl.inflight == 0 && now.Sub(cl.lastSeen) > limiterIdleTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	cl, ok := l.clients[key]
+	if !ok {
+		cl = &clientLimit{}
+		if l.rule.Rate > 0 {
+			cl.bucket = service.NewTokenBucket(l.rule.Rate, l.rule.Burst)
+		}
+		l.clients[key] = cl
+	}
+	cl.lastSeen = now
+
+	if l.rule.Concurrency > 0 && cl.inflight >= l.rule.Concurrency {
+		return time.Second, false
+	}
+	if cl.bucket != nil {
+		if wait, ok := cl.bucket.Reserve(0); !ok {
+			return wait, false
+		}
+	}
+	cl.inflight++
+	return 0, true
+}
+
+func (l *routeLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cl, ok := l.clients[key]; ok && cl.inflight > 0 {
+		cl.inflight--
+	}
+}
+
+// rateLimitKey 已鉴权的请求按 API Key 计数，其余按客户端 IP 计数
+func rateLimitKey(c *gin.Context) string {
+	if name := c.GetString(authNameKey); name != "" {
+		return "key:" + name
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimit 对一类路由按客户端限流与限制并发，超出时返回 429 并附带 Retry-After
+func RateLimit(rule config.RateLimitRule) gin.HandlerFunc {
+	if rule.Rate <= 0 && rule.Concurrency <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	l := &routeLimiter{rule: rule, clients: make(map[string]*clientLimit), lastSweep: time.Now()}
+	return func(c *gin.Context) {
+		key := rateLimitKey(c)
+		wait, ok := l.acquire(key)
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondError(c, ErrCodeTooManyRequests, "too many requests", nil)
+			c.Abort()
+			return
+		}
+		defer l.release(key)
+		c.Next()
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+// SourceStatusHeader 扇出请求在该响应头中报告各平台的结果，如 "kuwo=circuit_open, netease=ok"
+const SourceStatusHeader = "X-Source-Status"
+
+// sourceReport 收集扇出请求中各平台的结果：ok、错误类型，熔断跳过的平台为 circuit_open
+type sourceReport struct {
+	mu     sync.Mutex
+	status map[string]string
+}
+
+func newSourceReport() *sourceReport {
+	return &sourceReport{status: make(map[string]string)}
+}
+
+// skip 平台处于熔断冷却期时记录 circuit_open 并返回 true，调用方应直接跳过该平台
+func (r *sourceReport) skip(source string) bool {
+	if !service.CB.Open(source) {
+		return false
+	}
+	r.record(source, service.NewSourceError(source, service.ErrCircuitOpen, nil))
+	return true
+}
+
+// record 记录平台的调用结果，同一平台多次调用时以最后一次为准
+func (r *sourceReport) record(source string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status[source] = service.UpstreamResult(err)
+}
+
+// statuses 返回各平台结果的副本
+func (r *sourceReport) statuses() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make(map[string]string, len(r.status))
+	for k, v := range r.status {
+		result[k] = v
+	}
+	return result
+}
+
+// write 将各平台结果写入响应头
+func (r *sourceReport) write(c *gin.Context) {
+	statuses := r.statuses()
+	if len(statuses) == 0 {
+		return
+	}
+	parts := make([]string, 0, len(statuses))
+	for source, status := range statuses {
+		parts = append(parts, source+"="+status)
+	}
+	sort.Strings(parts)
+	c.Header(SourceStatusHeader, strings.Join(parts, ", "))
+}
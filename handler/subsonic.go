@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+// Subsonic 协议版本与服务标识
+const (
+	subsonicAPIVersion    = "1.16.1"
+	subsonicServerType    = "go-music-api"
+	subsonicServerVersion = "1.0"
+	subsonicXMLNS         = "http://subsonic.org/restapi"
+)
+
+// Subsonic 错误码
+const (
+	subsonicErrGeneric      = 0
+	subsonicErrMissingParam = 10
+	subsonicErrWrongAuth    = 40
+	subsonicErrTokenAuth    = 41 // 该用户不支持令牌(t/s)鉴权
+	subsonicErrForbidden    = 50
+	subsonicErrInvalidKey   = 44 // OpenSubsonic: apiKey 无效
+	subsonicErrNotFound     = 70
+)
+
+// 错误码目录到 Subsonic 错误码的映射，未列出的按通用错误处理
+var subsonicErrorCodes = map[string]int{
+	ErrCodeMissingParameter: subsonicErrMissingParam,
+	ErrCodeUnauthorized:     subsonicErrWrongAuth,
+	ErrCodeForbidden:        subsonicErrForbidden,
+	ErrCodeNotFound:         subsonicErrNotFound,
+}
+
+const subsonicModeKey = "subsonic_mode"
+
+func isSubsonic(c *gin.Context) bool {
+	return c.GetBool(subsonicModeKey)
+}
+
+// subsonicResponse Subsonic 响应，XML 与 JSON 共用；同一响应只会填充一种数据
+type subsonicResponse struct {
+	XMLName       xml.Name `xml:"subsonic-response" json:"-"`
+	XMLNS         string   `xml:"xmlns,attr" json:"-"`
+	Status        string   `xml:"status,attr" json:"status"`
+	Version       string   `xml:"version,attr" json:"version"`
+	Type          string   `xml:"type,attr" json:"type"`
+	ServerVersion string   `xml:"serverVersion,attr" json:"serverVersion"`
+	OpenSubsonic  bool     `xml:"openSubsonic,attr" json:"openSubsonic"`
+
+	Error         *subsonicError         `xml:"error,omitempty" json:"error,omitempty"`
+	License       *subsonicLicense       `xml:"license,omitempty" json:"license,omitempty"`
+	SearchResult3 *subsonicSearchResult3 `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	Song          *subsonicSong          `xml:"song,omitempty" json:"song,omitempty"`
+	Album         *subsonicAlbum         `xml:"album,omitempty" json:"album,omitempty"`
+	Playlists     *subsonicPlaylists     `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist      *subsonicPlaylist      `xml:"playlist,omitempty" json:"playlist,omitempty"`
+	Lyrics        *subsonicLyrics        `xml:"lyrics,omitempty" json:"lyrics,omitempty"`
+}
+
+type subsonicError struct {
+	Code    int    `xml:"code,attr" json:"code"`
+	Message string `xml:"message,attr" json:"message"`
+}
+
+type subsonicLicense struct {
+	Valid bool `xml:"valid,attr" json:"valid"`
+}
+
+// subsonicRespond 按 f 参数输出 XML(默认)或 JSON
+func subsonicRespond(c *gin.Context, resp subsonicResponse) {
+	resp.XMLNS = subsonicXMLNS
+	resp.Version = subsonicAPIVersion
+	resp.Type = subsonicServerType
+	resp.ServerVersion = subsonicServerVersion
+	resp.OpenSubsonic = true
+	if resp.Status == "" {
+		resp.Status = "ok"
+	}
+	if subsonicParam(c, "f") == "json" {
+		c.JSON(200, gin.H{"subsonic-response": resp})
+		return
+	}
+	c.XML(200, resp)
+}
+
+// subsonicFail 返回 Subsonic 错误响应；按协议 HTTP 状态码仍为 200
+func subsonicFail(c *gin.Context, code int, msg string) {
+	subsonicRespond(c, subsonicResponse{Status: "failed", Error: &subsonicError{Code: code, Message: msg}})
+}
+
+// subsonicParam 读取查询参数或表单参数，客户端可以用 POST 表单提交参数
+func subsonicParam(c *gin.Context, name string) string {
+	return strings.TrimSpace(c.Request.FormValue(name))
+}
+
+// ==========================================
+// ID 编码
+// ==========================================
+
+// Subsonic ID 的类型前缀
+const (
+	subsonicSongID     = "tr"
+	subsonicAlbumID    = "al"
+	subsonicPlaylistID = "pl"
+)
+
+// encodeSubsonicID 生成形如 tr:netease:<平台 ID> 的 ID，并保存其元数据
+func encodeSubsonicID(kind, source string, ref service.SubsonicRef) string {
+	id := kind + ":" + source + ":" + ref.ID
+	service.SIDS.Put(id, ref)
+	return id
+}
+
+// decodeSubsonicID 解析 ID，kinds 为空时接受任意类型。
+// 没有保存元数据（如超过保留时长被清理）时 ref 只有平台 ID
+func decodeSubsonicID(id string, kinds ...string) (kind, source string, ref service.SubsonicRef, ok bool) {
+	parts := strings.SplitN(id, ":", 3)
+	if len(parts) != 3 || parts[2] == "" || (len(kinds) > 0 && !slices.Contains(kinds, parts[0])) {
+		return "", "", ref, false
+	}
+	if !slices.Contains(service.GetAllSourceNames(), parts[1]) {
+		return "", "", ref, false
+	}
+	if ref, ok = service.SIDS.Get(id); !ok {
+		ref = service.SubsonicRef{ID: parts[2]}
+	}
+	return parts[0], parts[1], ref, true
+}
+
+// ==========================================
+// 鉴权
+// ==========================================
+
+// SubsonicAuth 按 Subsonic 协议鉴权并标记请求，之后的错误都以 Subsonic 格式返回。
+// u 为 API Key 的名称时，p 为 Key 本身，或以 t=md5(Key+s) 的令牌方式提交；
+// u 为本地用户名时，p 为该用户的 API 令牌(mu_ 开头)，令牌只保存摘要，不支持 t/s 方式。
+// 也支持 OpenSubsonic 的 apiKey 参数。未配置任何 API Key 时沿用旧版行为，所有请求视为管理员。
+func SubsonicAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(subsonicModeKey, true)
+		if code, msg := subsonicAuthenticate(c); code != 0 {
+			subsonicFail(c, code, msg)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func subsonicAuthenticate(c *gin.Context) (int, string) {
+	open := len(config.C.Auth.Keys) == 0
+	setUser := func(username string) {
+		role := config.RoleUser
+		if open {
+			role = config.RoleAdmin
+		}
+		c.Set(authRoleKey, role)
+		c.Set(authNameKey, "user:"+username)
+		c.Set(authUserKey, username)
+	}
+	setKey := func(k *config.APIKey) {
+		c.Set(authRoleKey, k.Role)
+		c.Set(authNameKey, k.Name)
+	}
+
+	if key := subsonicParam(c, "apiKey"); key != "" {
+		if username, ok := service.US.LookupToken(key); ok {
+			setUser(username)
+			return 0, ""
+		}
+		if k := lookupAPIKey(key); k != nil {
+			setKey(k)
+			return 0, ""
+		}
+		if open {
+			c.Set(authRoleKey, config.RoleAdmin)
+			return 0, ""
+		}
+		return subsonicErrInvalidKey, "invalid api key"
+	}
+
+	u, p := subsonicParam(c, "u"), subsonicParam(c, "p")
+	t, s := strings.ToLower(subsonicParam(c, "t")), subsonicParam(c, "s")
+	if strings.HasPrefix(p, "enc:") {
+		if decoded, err := hex.DecodeString(p[4:]); err == nil {
+			p = string(decoded)
+		}
+	}
+	switch {
+	case u != "" && p != "":
+		if username, ok := service.US.LookupToken(p); ok && username == u {
+			setUser(username)
+			return 0, ""
+		}
+		if k := lookupAPIKey(p); k != nil && k.Name == u {
+			setKey(k)
+			return 0, ""
+		}
+	case u != "" && t != "" && s != "":
+		for i := range config.C.Auth.Keys {
+			k := &config.C.Auth.Keys[i]
+			sum := md5.Sum([]byte(k.Key + s))
+			if k.Name == u && subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(t)) == 1 {
+				setKey(k)
+				return 0, ""
+			}
+		}
+		if _, ok := service.US.Info(u); ok && !open {
+			return subsonicErrTokenAuth, "token authentication is not supported for local users, use p with a user api token"
+		}
+	case !open:
+		return subsonicErrMissingParam, "required parameter is missing: u and p, or u, t and s"
+	}
+	if open {
+		c.Set(authRoleKey, config.RoleAdmin)
+		return 0, ""
+	}
+	return subsonicErrWrongAuth, "wrong username or password"
+}
@@ -0,0 +1,501 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/service"
+	"github.com/guohuiyuan/music-lib/model"
+)
+
+type subsonicSong struct {
+	ID          string `xml:"id,attr" json:"id"`
+	Parent      string `xml:"parent,attr,omitempty" json:"parent,omitempty"`
+	IsDir       bool   `xml:"isDir,attr" json:"isDir"`
+	Title       string `xml:"title,attr" json:"title"`
+	Album       string `xml:"album,attr,omitempty" json:"album,omitempty"`
+	Artist      string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	CoverArt    string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Size        int64  `xml:"size,attr,omitempty" json:"size,omitempty"`
+	ContentType string `xml:"contentType,attr" json:"contentType"`
+	Suffix      string `xml:"suffix,attr" json:"suffix"`
+	Duration    int    `xml:"duration,attr,omitempty" json:"duration,omitempty"`
+	BitRate     int    `xml:"bitRate,attr,omitempty" json:"bitRate,omitempty"`
+	AlbumID     string `xml:"albumId,attr,omitempty" json:"albumId,omitempty"`
+	Type        string `xml:"type,attr" json:"type"`
+	MediaType   string `xml:"mediaType,attr" json:"mediaType"`
+}
+
+type subsonicAlbum struct {
+	ID        string         `xml:"id,attr" json:"id"`
+	Name      string         `xml:"name,attr" json:"name"`
+	Artist    string         `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	CoverArt  string         `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	SongCount int            `xml:"songCount,attr" json:"songCount"`
+	Duration  int            `xml:"duration,attr" json:"duration"`
+	Created   string         `xml:"created,attr" json:"created"`
+	Song      []subsonicSong `xml:"song" json:"song,omitempty"`
+}
+
+type subsonicPlaylist struct {
+	ID        string         `xml:"id,attr" json:"id"`
+	Name      string         `xml:"name,attr" json:"name"`
+	Comment   string         `xml:"comment,attr,omitempty" json:"comment,omitempty"`
+	Owner     string         `xml:"owner,attr,omitempty" json:"owner,omitempty"`
+	Public    bool           `xml:"public,attr" json:"public"`
+	SongCount int            `xml:"songCount,attr" json:"songCount"`
+	Duration  int            `xml:"duration,attr" json:"duration"`
+	Created   string         `xml:"created,attr" json:"created"`
+	Changed   string         `xml:"changed,attr" json:"changed"`
+	CoverArt  string         `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Entry     []subsonicSong `xml:"entry" json:"entry,omitempty"`
+}
+
+type subsonicPlaylists struct {
+	Playlist []subsonicPlaylist `xml:"playlist" json:"playlist,omitempty"`
+}
+
+type subsonicSearchResult3 struct {
+	Album []subsonicAlbum `xml:"album" json:"album,omitempty"`
+	Song  []subsonicSong  `xml:"song" json:"song,omitempty"`
+}
+
+type subsonicLyrics struct {
+	Artist string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Title  string `xml:"title,attr,omitempty" json:"title,omitempty"`
+	Value  string `xml:",chardata" json:"value"`
+}
+
+// 常见音频后缀对应的 MIME 类型，未知后缀按 mp3 处理
+var subsonicContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"flac": "audio/flac",
+	"m4a":  "audio/mp4",
+	"aac":  "audio/aac",
+	"ogg":  "audio/ogg",
+	"wav":  "audio/wav",
+}
+
+// 平台没有创建与修改时间时统一使用服务启动时间
+func subsonicCreated() string {
+	return startedAt.UTC().Format(time.RFC3339)
+}
+
+// subsonicCoverArt 封面 ID 复用所属歌曲、专辑或歌单的 ID，封面地址从其保存的元数据中读取
+func subsonicCoverArt(id, cover string) string {
+	if cover == "" {
+		return ""
+	}
+	return id
+}
+
+func subsonicSongFrom(song model.Song) subsonicSong {
+	suffix := strings.ToLower(song.Ext)
+	contentType, ok := subsonicContentTypes[suffix]
+	if !ok {
+		suffix, contentType = "mp3", subsonicContentTypes["mp3"]
+	}
+	s := subsonicSong{
+		ID: encodeSubsonicID(subsonicSongID, song.Source, service.SubsonicRef{
+			ID:       song.ID,
+			Name:     song.Name,
+			Artist:   song.Artist,
+			Album:    song.Album,
+			AlbumID:  song.AlbumID,
+			Duration: song.Duration,
+			Cover:    song.Cover,
+			Ext:      song.Ext,
+			Extra:    song.Extra,
+		}),
+		Title:       song.Name,
+		Album:       song.Album,
+		Artist:      song.Artist,
+		Size:        song.Size,
+		ContentType: contentType,
+		Suffix:      suffix,
+		Duration:    song.Duration,
+		BitRate:     song.Bitrate,
+		Type:        "music",
+		MediaType:   "song",
+	}
+	s.CoverArt = subsonicCoverArt(s.ID, song.Cover)
+	if song.AlbumID != "" {
+		s.AlbumID = encodeSubsonicID(subsonicAlbumID, song.Source, service.SubsonicRef{ID: song.AlbumID, Name: song.Album, Artist: song.Artist, Cover: song.Cover})
+		s.Parent = s.AlbumID
+	}
+	return s
+}
+
+func subsonicSongsFrom(songs []model.Song, source string) ([]subsonicSong, int) {
+	result := make([]subsonicSong, 0, len(songs))
+	duration := 0
+	for _, song := range songs {
+		if song.Source == "" {
+			song.Source = source
+		}
+		result = append(result, subsonicSongFrom(song))
+		duration += song.Duration
+	}
+	return result, duration
+}
+
+func subsonicAlbumFrom(album model.Playlist) subsonicAlbum {
+	id := encodeSubsonicID(subsonicAlbumID, album.Source, service.SubsonicRef{ID: album.ID, Name: album.Name, Artist: album.Creator, Cover: album.Cover})
+	return subsonicAlbum{
+		ID:        id,
+		Name:      album.Name,
+		Artist:    album.Creator,
+		CoverArt:  subsonicCoverArt(id, album.Cover),
+		SongCount: album.TrackCount,
+		Created:   subsonicCreated(),
+	}
+}
+
+func subsonicPlaylistFrom(p model.Playlist) subsonicPlaylist {
+	id := encodeSubsonicID(subsonicPlaylistID, p.Source, service.SubsonicRef{ID: p.ID, Name: p.Name, Artist: p.Creator, Cover: p.Cover})
+	return subsonicPlaylist{
+		ID:        id,
+		Name:      p.Name,
+		Comment:   p.Description,
+		Owner:     p.Creator,
+		SongCount: p.TrackCount,
+		Created:   subsonicCreated(),
+		Changed:   subsonicCreated(),
+		CoverArt:  subsonicCoverArt(id, p.Cover),
+	}
+}
+
+func subsonicIntParam(c *gin.Context, name string, fallback int) int {
+	value, err := strconv.Atoi(subsonicParam(c, name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// subsonicPage 按 offset 与 count 截取结果
+func subsonicPage[T any](items []T, offset, count int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	return items[offset:min(offset+count, len(items))]
+}
+
+// subsonicSongParam 从 id 参数解析歌曲，无效时返回 Subsonic 错误
+func subsonicSongParam(c *gin.Context) (*model.Song, bool) {
+	id := subsonicParam(c, "id")
+	if id == "" {
+		subsonicFail(c, subsonicErrMissingParam, "required parameter is missing: id")
+		return nil, false
+	}
+	_, source, ref, ok := decodeSubsonicID(id, subsonicSongID)
+	if !ok || ref.ID == "" {
+		subsonicFail(c, subsonicErrNotFound, "song not found")
+		return nil, false
+	}
+	return &model.Song{
+		ID:       ref.ID,
+		Source:   source,
+		Name:     ref.Name,
+		Artist:   ref.Artist,
+		Album:    ref.Album,
+		AlbumID:  ref.AlbumID,
+		Duration: ref.Duration,
+		Cover:    ref.Cover,
+		Ext:      ref.Ext,
+		Extra:    ref.Extra,
+	}, true
+}
+
+// SubsonicPing 连通性测试
+// @Summary Subsonic: ping
+// @Description 供 Subsonic 客户端测试连接与鉴权。鉴权使用 u 与 p，或 u、t=md5(password+s) 与 s，也支持 OpenSubsonic 的 apiKey；f=json 时返回 JSON，默认 XML。以下 Subsonic 接口同时支持 .view 后缀与 POST 表单。
+// @Tags Subsonic
+// @Produce xml,json
+// @Param u query string false "API Key 名称或本地用户名"
+// @Param p query string false "API Key 或用户令牌，支持 enc: 十六进制编码"
+// @Param t query string false "md5(password+salt)"
+// @Param s query string false "盐"
+// @Param f query string false "响应格式" Enums(xml, json)
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/ping [get]
+func SubsonicPing(c *gin.Context) {
+	subsonicRespond(c, subsonicResponse{})
+}
+
+// SubsonicGetLicense 许可状态
+// @Summary Subsonic: getLicense
+// @Description 始终返回有效许可。
+// @Tags Subsonic
+// @Produce xml,json
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/getLicense [get]
+func SubsonicGetLicense(c *gin.Context) {
+	subsonicRespond(c, subsonicResponse{License: &subsonicLicense{Valid: true}})
+}
+
+// SubsonicSearch3 搜索歌曲与专辑
+// @Summary Subsonic: search3
+// @Description 对应 /api/v1/music/search，并发搜索默认平台的单曲与专辑，按平台顺序合并后分页；不返回歌手。空关键词返回空结果。
+// @Tags Subsonic
+// @Produce xml,json
+// @Param query query string true "关键词"
+// @Param songCount query int false "歌曲数量" default(20)
+// @Param songOffset query int false "歌曲偏移" default(0)
+// @Param albumCount query int false "专辑数量" default(20)
+// @Param albumOffset query int false "专辑偏移" default(0)
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/search3 [get]
+func SubsonicSearch3(c *gin.Context) {
+	query := strings.Trim(subsonicParam(c, "query"), `"`)
+	songCount := subsonicIntParam(c, "songCount", 20)
+	albumCount := subsonicIntParam(c, "albumCount", 20)
+	result := &subsonicSearchResult3{}
+	if query == "" {
+		subsonicRespond(c, subsonicResponse{SearchResult3: result})
+		return
+	}
+
+	ctx := c.Request.Context()
+	report := newSourceReport()
+	var songs []model.Song
+	var albums []model.Playlist
+	var wg sync.WaitGroup
+	if songCount > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			songs, _, _ = searchSources(ctx, query, "song", service.GetDefaultSourceNames(), report)
+		}()
+	}
+	if albumCount > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, albums = searchSources(ctx, query, "album", service.GetAlbumSourceNames(), report)
+		}()
+	}
+	wg.Wait()
+	report.write(c)
+
+	for _, song := range subsonicPage(songs, subsonicIntParam(c, "songOffset", 0), songCount) {
+		result.Song = append(result.Song, subsonicSongFrom(song))
+	}
+	for _, album := range subsonicPage(albums, subsonicIntParam(c, "albumOffset", 0), albumCount) {
+		result.Album = append(result.Album, subsonicAlbumFrom(album))
+	}
+	subsonicRespond(c, subsonicResponse{SearchResult3: result})
+}
+
+// SubsonicGetSong 歌曲详情
+// @Summary Subsonic: getSong
+// @Description 歌曲信息取自服务端保存的搜索与列表结果，不访问上游平台，服务重启后仍然有效；超过 180 天未再出现在结果中的 ID 只返回平台 ID。
+// @Tags Subsonic
+// @Produce xml,json
+// @Param id query string true "歌曲 ID"
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/getSong [get]
+func SubsonicGetSong(c *gin.Context) {
+	song, ok := subsonicSongParam(c)
+	if !ok {
+		return
+	}
+	s := subsonicSongFrom(*song)
+	subsonicRespond(c, subsonicResponse{Song: &s})
+}
+
+// SubsonicStream 串流歌曲
+// @Summary Subsonic: stream
+// @Description 对应 /api/v1/music/stream，支持 Range；不转码，maxBitRate 与 format 参数被忽略。
+// @Tags Subsonic
+// @Produce audio/mpeg
+// @Param id query string true "歌曲 ID"
+// @Success 200 {file} file "音频流"
+// @Router /rest/stream [get]
+func SubsonicStream(c *gin.Context) {
+	song, ok := subsonicSongParam(c)
+	if !ok {
+		return
+	}
+	streamSong(c, song)
+}
+
+// SubsonicGetCoverArt 封面图
+// @Summary Subsonic: getCoverArt
+// @Description 对应 /api/v1/music/cover，按封面域名所属平台的请求头拉取图片；size 参数被忽略。id 为歌曲、专辑或歌单 ID，封面地址取自服务端保存的元数据，域名不属于该平台时拒绝访问。
+// @Tags Subsonic
+// @Produce image/jpeg
+// @Param id query string true "封面 ID"
+// @Success 200 {file} file "图片"
+// @Router /rest/getCoverArt [get]
+func SubsonicGetCoverArt(c *gin.Context) {
+	id := subsonicParam(c, "id")
+	if id == "" {
+		subsonicFail(c, subsonicErrMissingParam, "required parameter is missing: id")
+		return
+	}
+	_, source, ref, ok := decodeSubsonicID(id)
+	if !ok || ref.Cover == "" {
+		subsonicFail(c, subsonicErrNotFound, "cover art not found")
+		return
+	}
+	// 只访问平台自身的域名，避免服务被当作任意地址的请求代理
+	if u, err := url.Parse(ref.Cover); err != nil || service.SourceForHost(u.Hostname()) != source {
+		subsonicFail(c, subsonicErrNotFound, "cover art not found")
+		return
+	}
+	data, err := service.FetchCover(c.Request.Context(), ref.Cover)
+	if err != nil {
+		respondSourceError(c, source, err, nil)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(200, http.DetectContentType(data), data)
+}
+
+// SubsonicGetLyrics 按歌名与歌手查找歌词
+// @Summary Subsonic: getLyrics
+// @Description 对应 /api/v1/music/lyric 的跨平台回退查找，按歌名与歌手匹配歌曲后返回去掉时间标签的纯文本歌词，找不到时返回空歌词。
+// @Tags Subsonic
+// @Produce xml,json
+// @Param artist query string false "歌手"
+// @Param title query string true "歌名"
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/getLyrics [get]
+func SubsonicGetLyrics(c *gin.Context) {
+	artist, title := subsonicParam(c, "artist"), subsonicParam(c, "title")
+	lyrics := &subsonicLyrics{}
+	if title != "" {
+		res := fetchLyric(c.Request.Context(), &model.Song{Name: title, Artist: artist}, true)
+		if res.err == nil {
+			lines := service.ParseLRC(res.lyric, res.song.Duration)
+			text := make([]string, 0, len(lines))
+			for _, line := range lines {
+				text = append(text, line.Text)
+			}
+			lyrics = &subsonicLyrics{Artist: res.song.Artist, Title: res.song.Name, Value: strings.Join(text, "\n")}
+		}
+	}
+	subsonicRespond(c, subsonicResponse{Lyrics: lyrics})
+}
+
+// SubsonicGetPlaylists 个人歌单
+// @Summary Subsonic: getPlaylists
+// @Description 对应 /api/v1/playlist/user，汇总已配置 Cookie 的平台上登录账号的个人歌单。
+// @Tags Subsonic
+// @Produce xml,json
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/getPlaylists [get]
+func SubsonicGetPlaylists(c *gin.Context) {
+	ctx := c.Request.Context()
+	sources := service.GetPlaylistSourceNames()
+	results := make([][]model.Playlist, len(sources))
+	report := newSourceReport()
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		fn := service.GetUserPlaylistsFunc(ctx, src)
+		if fn == nil || !service.HasCookie(ctx, src) || report.skip(src) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, s string) {
+			defer wg.Done()
+			res, err := fn(1, 100)
+			report.record(s, err)
+			if err == nil {
+				for j := range res {
+					res[j].Source = s
+				}
+				results[i] = res
+			}
+		}(i, src)
+	}
+	wg.Wait()
+	report.write(c)
+
+	playlists := &subsonicPlaylists{}
+	for _, res := range results {
+		for _, p := range res {
+			playlists.Playlist = append(playlists.Playlist, subsonicPlaylistFrom(p))
+		}
+	}
+	subsonicRespond(c, subsonicResponse{Playlists: playlists})
+}
+
+// SubsonicGetPlaylist 歌单详情
+// @Summary Subsonic: getPlaylist
+// @Description 对应 /api/v1/playlist/detail。
+// @Tags Subsonic
+// @Produce xml,json
+// @Param id query string true "歌单 ID"
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/getPlaylist [get]
+func SubsonicGetPlaylist(c *gin.Context) {
+	_, source, ref, ok := decodeSubsonicID(subsonicParam(c, "id"), subsonicPlaylistID)
+	if !ok || ref.ID == "" {
+		subsonicFail(c, subsonicErrNotFound, "playlist not found")
+		return
+	}
+	fn := service.GetPlaylistDetailFunc(c.Request.Context(), source)
+	if fn == nil {
+		respondError(c, ErrCodeSourceUnsupported, "不支持获取该源的歌单", nil)
+		return
+	}
+	songs, err := fn(ref.ID)
+	if err != nil {
+		respondSourceError(c, source, err, nil)
+		return
+	}
+	playlist := subsonicPlaylistFrom(model.Playlist{ID: ref.ID, Name: ref.Name, Creator: ref.Artist, Cover: ref.Cover, Source: source})
+	playlist.Entry, playlist.Duration = subsonicSongsFrom(songs, source)
+	playlist.ID = subsonicParam(c, "id")
+	playlist.SongCount = len(playlist.Entry)
+	subsonicRespond(c, subsonicResponse{Playlist: &playlist})
+}
+
+// SubsonicGetAlbum 专辑详情
+// @Summary Subsonic: getAlbum
+// @Description 对应 /api/v1/album/detail。
+// @Tags Subsonic
+// @Produce xml,json
+// @Param id query string true "专辑 ID"
+// @Success 200 {object} map[string]interface{} "subsonic-response"
+// @Router /rest/getAlbum [get]
+func SubsonicGetAlbum(c *gin.Context) {
+	_, source, ref, ok := decodeSubsonicID(subsonicParam(c, "id"), subsonicAlbumID)
+	if !ok || ref.ID == "" {
+		subsonicFail(c, subsonicErrNotFound, "album not found")
+		return
+	}
+	fn := service.GetAlbumDetailFunc(c.Request.Context(), source)
+	if fn == nil {
+		respondError(c, ErrCodeSourceUnsupported, "不支持获取该源的专辑", nil)
+		return
+	}
+	songs, err := fn(ref.ID)
+	if err != nil {
+		respondSourceError(c, source, err, nil)
+		return
+	}
+	if len(songs) > 0 {
+		if ref.Name == "" {
+			ref.Name = songs[0].Album
+		}
+		if ref.Artist == "" {
+			ref.Artist = songs[0].Artist
+		}
+		if ref.Cover == "" {
+			ref.Cover = songs[0].Cover
+		}
+	}
+	album := subsonicAlbumFrom(model.Playlist{ID: ref.ID, Name: ref.Name, Creator: ref.Artist, Cover: ref.Cover, Source: source})
+	album.Song, album.Duration = subsonicSongsFrom(songs, source)
+	album.ID = subsonicParam(c, "id")
+	album.SongCount = len(album.Song)
+	subsonicRespond(c, subsonicResponse{Album: &album})
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+func subsonicToken(password, salt string) string {
+	sum := md5.Sum([]byte(password + salt))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSubsonicAuth(t *testing.T) {
+	userToken := loadTestUsers(t)
+	old := config.C.Auth.Keys
+	t.Cleanup(func() { config.C.Auth.Keys = old })
+	config.C.Auth.Keys = []config.APIKey{{Name: "web", Key: "client-key", Role: config.RoleClient}}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/rest/ping", SubsonicAuth(), SubsonicPing)
+
+	tests := []struct {
+		name   string
+		params url.Values
+		code   int // 0 表示鉴权通过
+	}{
+		{"api key password", url.Values{"u": {"web"}, "p": {"client-key"}}, 0},
+		{"api key hex password", url.Values{"u": {"web"}, "p": {"enc:" + hex.EncodeToString([]byte("client-key"))}}, 0},
+		{"api key token", url.Values{"u": {"web"}, "t": {subsonicToken("client-key", "salt")}, "s": {"salt"}}, 0},
+		{"api key wrong token", url.Values{"u": {"web"}, "t": {subsonicToken("wrong", "salt")}, "s": {"salt"}}, subsonicErrWrongAuth},
+		{"user token as password", url.Values{"u": {"alice"}, "p": {userToken}}, 0},
+		{"user token as apiKey", url.Values{"apiKey": {userToken}}, 0},
+		// 用户令牌只保存摘要，无法校验 md5(令牌+盐)，明确返回 41 提示客户端改用 p 或 apiKey
+		{"user token auth unsupported", url.Values{"u": {"alice"}, "t": {subsonicToken(userToken, "salt")}, "s": {"salt"}}, subsonicErrTokenAuth},
+		{"user token for another user", url.Values{"u": {"bob"}, "p": {userToken}}, subsonicErrWrongAuth},
+		{"missing credentials", url.Values{}, subsonicErrMissingParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.Set("f", "json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/rest/ping?"+tt.params.Encode(), nil))
+			var body struct {
+				Response subsonicResponse `json:"subsonic-response"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %s: %v", w.Body, err)
+			}
+			code := 0
+			if body.Response.Error != nil {
+				code = body.Response.Error.Code
+			}
+			if code != tt.code {
+				t.Errorf("error code = %d, want %d: %s", code, tt.code, w.Body)
+			}
+		})
+	}
+}
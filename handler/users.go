@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/service"
+)
+
+type userCredentials struct {
+	Username  string `json:"username" example:"alice"`
+	Password  string `json:"password" example:"correct-horse-battery"`
+	TokenName string `json:"token_name,omitempty" example:"desktop"`
+}
+
+type userTokenRequest struct {
+	Name string `json:"name" example:"bot"`
+}
+
+type userTokenResponse struct {
+	Token string                `json:"token"`
+	Info  service.UserTokenInfo `json:"info"`
+}
+
+// RegisterUser 注册用户
+// @Summary 注册本地用户
+// @Description 创建用户账号，密码使用 bcrypt 哈希保存。未开启 users.open_registration 时只有管理员可以创建用户。
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param user body userCredentials true "用户名与密码"
+// @Success 200 {object} Response "新用户信息"
+// @Failure 400 {object} Response "用户名或密码不合法、用户名已存在"
+// @Failure 403 {object} Response "未开放注册"
+// @Router /api/v1/users/register [post]
+func RegisterUser(c *gin.Context) {
+	if !config.C.Users.OpenRegistration && !isAdmin(c) {
+		respondError(c, ErrCodeForbidden, "registration is closed", nil)
+		return
+	}
+	var req userCredentials
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeBadRequest, "Invalid JSON", nil)
+		return
+	}
+	info, err := service.US.Register(req.Username, req.Password)
+	if errors.Is(err, service.ErrUserExists) || errors.Is(err, service.ErrInvalidUser) {
+		respondError(c, ErrCodeBadRequest, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: info})
+}
+
+// LoginUser 用户登录
+// @Summary 登录并获取 API 令牌
+// @Description 校验用户名与密码后签发新的 API 令牌。令牌只在此时返回一次，之后通过 X-API-Key 或 Authorization: Bearer 携带。
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param user body userCredentials true "用户名与密码"
+// @Success 200 {object} Response "API 令牌"
+// @Failure 400 {object} Response "参数错误"
+// @Failure 401 {object} Response "用户名或密码错误"
+// @Router /api/v1/users/login [post]
+func LoginUser(c *gin.Context) {
+	var req userCredentials
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeBadRequest, "Invalid JSON", nil)
+		return
+	}
+	token, info, err := service.US.Login(req.Username, req.Password, req.TokenName)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		respondError(c, ErrCodeUnauthorized, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		respondError(c, ErrCodeInternal, err.Error(), nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: userTokenResponse{Token: token, Info: info}})
+}
+
+// GetCurrentUser 当前用户信息
+// @Summary 获取当前用户信息
+// @Description 返回当前用户的令牌列表(不含令牌本身)与脱敏后的个人 Cookie。
+// @Tags Users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} Response "用户信息"
+// @Failure 401 {object} Response "需要用户令牌"
+// @Router /api/v1/users/me [get]
+func GetCurrentUser(c *gin.Context) {
+	info, ok := service.US.Info(currentUser(c))
+	if !ok {
+		respondError(c, ErrCodeNotFound, "user not found", nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: info})
+}
+
+// CreateUserToken 签发 API 令牌
+// @Summary 为当前用户签发新的 API 令牌
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param token body userTokenRequest false "令牌备注"
+// @Success 200 {object} Response "API 令牌"
+// @Failure 401 {object} Response "需要用户令牌"
+// @Router /api/v1/users/me/tokens [post]
+func CreateUserToken(c *gin.Context) {
+	var req userTokenRequest
+	_ = c.ShouldBindJSON(&req)
+	token, info, err := service.US.CreateToken(currentUser(c), req.Name)
+	if err != nil {
+		respondSourceError(c, "", err, nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: userTokenResponse{Token: token, Info: info}})
+}
+
+// RevokeUserToken 吊销 API 令牌
+// @Summary 吊销当前用户的 API 令牌
+// @Tags Users
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "令牌 ID"
+// @Success 200 {object} Response "操作成功"
+// @Failure 401 {object} Response "需要用户令牌"
+// @Failure 404 {object} Response "令牌不存在"
+// @Router /api/v1/users/me/tokens/{id} [delete]
+func RevokeUserToken(c *gin.Context) {
+	if err := service.US.RevokeToken(currentUser(c), c.Param("id")); err != nil {
+		respondSourceError(c, "", err, nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success"})
+}
+
+// GetUserCookies 个人 Cookie 保险箱
+// @Summary 查看当前用户的个人 Cookie
+// @Description 返回脱敏后的个人 Cookie。以用户令牌调用其它接口时，这些 Cookie 优先于服务端账号池使用。
+// @Tags Users
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]string "平台 Cookie 键值对(脱敏)"
+// @Failure 401 {object} Response "需要用户令牌"
+// @Router /api/v1/users/me/cookies [get]
+func GetUserCookies(c *gin.Context) {
+	cookies := map[string]string{}
+	for source, cookie := range service.US.VaultCookies(currentUser(c)) {
+		cookies[source] = service.MaskCookie(cookie)
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success", Data: cookies})
+}
+
+// SetUserCookies 更新个人 Cookie 保险箱
+// @Summary 更新当前用户的个人 Cookie
+// @Description 接收平台 Cookie 键值对写入个人保险箱，值为空字符串表示删除该平台。
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param cookies body map[string]string true "平台Cookies映射示例：{\"netease\": \"MUSIC_U=xxx;\"}"
+// @Success 200 {object} Response "操作成功"
+// @Failure 400 {object} Response "参数解析失败或平台不支持"
+// @Failure 401 {object} Response "需要用户令牌"
+// @Router /api/v1/users/me/cookies [post]
+func SetUserCookies(c *gin.Context) {
+	var req map[string]string
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, ErrCodeBadRequest, "Invalid JSON", nil)
+		return
+	}
+	for source := range req {
+		if !slices.Contains(service.GetAllSourceNames(), strings.TrimSpace(source)) {
+			respondError(c, ErrCodeSourceUnsupported, "unsupported source: "+source, nil)
+			return
+		}
+	}
+	if err := service.US.SetVaultCookies(currentUser(c), req); err != nil {
+		respondSourceError(c, "", err, nil)
+		return
+	}
+	c.JSON(200, Response{Code: 200, Msg: "success"})
+}
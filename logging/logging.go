@@ -0,0 +1,115 @@
+// Package logging 基于 log/slog 输出结构化日志，自动附加请求 ID 并隐去 Cookie、密钥等敏感字段
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// Redacted 敏感字段在日志中的替代值
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys 名称包含这些片段的日志字段与查询参数会被隐去
+var sensitiveKeys = []string{"cookie", "password", "secret", "token", "api_key", "apikey", "authorization"}
+
+// sensitiveParams 需要按全名匹配的敏感查询参数：Subsonic 接口的 p、t、s 分别为密码、令牌与盐
+var sensitiveParams = []string{"p", "t", "s"}
+
+type requestIDKey struct{}
+
+// WithRequestID 在 context 中记录请求 ID，之后以该 context 输出的日志都会带上 request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 context 中的请求 ID，没有时为空串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Setup 按配置替换 slog 默认 logger，同时接管标准库 log 的输出
+func Setup(cfg config.LogConfig) {
+	opts := &slog.HandlerOptions{Level: parseLevel(cfg.Level), ReplaceAttr: redact}
+	var h slog.Handler
+	if cfg.Format == config.LogFormatText {
+		h = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		h = slog.NewJSONHandler(os.Stdout, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// Sensitive 判断字段或参数名是否属于需要隐去的敏感信息
+func Sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if Sensitive(a.Key) && a.Value.Kind() != slog.KindGroup {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// RedactQuery 隐去 URL 查询参数中的敏感值，保留参数顺序，用于记录请求路径
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if Sensitive(name) || slices.Contains(sensitiveParams, name) {
+			parts[i] = key + "=" + Redacted
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// contextHandler 从 context 中取出请求 ID 附加到每条日志
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
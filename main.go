@@ -3,23 +3,55 @@
 // @description 这是一个基于底层库构建的跨平台音乐搜索与解析统一 API 服务。
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
 package main
 
 import (
-	"fmt"
+	"log/slog"
 
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/logging"
 	"github.com/guohuiyuan/go-music-api/router"
 	"github.com/guohuiyuan/go-music-api/service"
 )
 
 func main() {
-	service.CM.Load()
-	fmt.Println("Cookies 已加载")
+	if err := config.Load(); err != nil {
+		panic("Failed to load config: " + err.Error())
+	}
+	logging.Setup(config.C.Log)
+	if config.C.Proxy.RoutesLibraryTraffic() {
+		service.RouteDefaultTransport()
+	}
+	if config.C.Log.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	if len(config.C.Auth.Keys) == 0 {
+		slog.Warn("未配置 API Key，/system 与 Cookie 相关接口对所有人开放")
+	}
+
+	if err := service.CM.Load(); err != nil {
+		panic("Failed to load cookies: " + err.Error())
+	}
+	slog.Info("Cookies 已加载")
+	service.CHC.Start()
+	service.SP.Start()
+	if err := service.US.Load(); err != nil {
+		panic("Failed to load users: " + err.Error())
+	}
+	if err := service.LOM.Load(); err != nil {
+		panic("Failed to load lyric offsets: " + err.Error())
+	}
+	if err := service.SIDS.Load(); err != nil {
+		panic("Failed to load subsonic ids: " + err.Error())
+	}
 
 	r := router.SetupRouter()
 
-	fmt.Println("Music API Server is running on http://localhost:8080")
-	fmt.Println("Swagger API 接口文档请访问: http://localhost:8080/swagger/index.html")
+	slog.Info("Music API Server is running", "addr", "http://localhost:8080", "swagger", "http://localhost:8080/swagger/index.html")
 	if err := r.Run(":8080"); err != nil {
 		panic("Failed to start server: " + err.Error())
 	}
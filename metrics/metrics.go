@@ -0,0 +1,95 @@
+// Package metrics 定义服务暴露给 Prometheus 的指标，由 handler 与 service 共同上报
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "music_api"
+
+// 上游调用结果标签，出错时为错误类型
+const (
+	ResultOK           = "ok"
+	ResultNotFound     = "not_found"
+	ResultAuthRequired = "auth_required"
+	ResultPaidContent  = "paid_content"
+	ResultRateLimited  = "rate_limited"
+	ResultTimeout      = "timeout"
+	ResultUpstream     = "upstream_error"
+	ResultCircuitOpen  = "circuit_open"
+)
+
+// 缓存查询结果标签
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+var (
+	// HTTPRequests 按路由模板、方法与状态码统计的请求数
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration 按路由模板与方法统计的处理耗时
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route template and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// UpstreamRequests 按平台、能力与结果统计的上游调用数，result 为 ok 或错误类型
+	UpstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_total",
+		Help:      "Calls to upstream music platforms, by source, capability and result (ok or error category).",
+	}, []string{"source", "capability", "result"})
+
+	// UpstreamDuration 按平台与能力统计的上游调用耗时，不含出站限流的排队时间
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of upstream platform calls, by source and capability.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30},
+	}, []string{"source", "capability"})
+
+	// UpstreamRetries 因瞬时错误重试的上游调用次数
+	UpstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Upstream calls retried after a transient error, by source and capability.",
+	}, []string{"source", "capability"})
+
+	// CircuitOpen 平台熔断器是否处于打开状态
+	CircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_open",
+		Help:      "Whether the circuit breaker for a source is open (1) or closed (0).",
+	}, []string{"source"})
+
+	// StreamedBytes 串流/下载接口按平台返回给客户端的音频字节数
+	StreamedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_bytes_total",
+		Help:      "Audio bytes sent to clients by the stream endpoint, by source.",
+	}, []string{"source"})
+
+	// CacheRequests 按缓存名称与结果统计的查询次数，用于计算命中率
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups, by cache name and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	// SodaDecryptDuration 汽水音乐音频解密耗时
+	SodaDecryptDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "soda_decrypt_duration_seconds",
+		Help:      "Time spent decrypting Soda audio.",
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+	})
+)
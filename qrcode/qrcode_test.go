@@ -0,0 +1,62 @@
+package qrcode
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// testdata 中的矩阵由 nayuki/QR-Code-generator v1.8.0 的 Python 实现生成：
+// QrCode.encode_segments([QrSegment.make_bytes(content)], Ecc.MEDIUM, boostecl=False)，
+// 深色模块记为 #，浅色记为 .，不含静区
+func TestEncodeGolden(t *testing.T) {
+	cases := []struct {
+		file    string
+		version int
+		content string
+	}{
+		{"version1.txt", 1, "https://b.cn/q"},
+		{"version7.txt", 7, "https://music.163.com/login?codekey=" + strings.Repeat("0123456789abcdef", 5)},
+		{"version10.txt", 10, "https://y.qq.com/ptqrlogin?u1=https%3A%2F%2Fgraph.qq.com%2Foauth2.0%2Flogin_jump" +
+			"&ptqrtoken=9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08&ptredirect=0&h=1&t=1&g=1&from_ui=1&ptlang=2052"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.file, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/" + tc.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Fields(string(data))
+
+			code, err := Encode(tc.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := 17 + 4*tc.version; code.Size != size || len(want) != size {
+				t.Fatalf("size = %d, golden rows = %d, want %d", code.Size, len(want), size)
+			}
+			for y, row := range want {
+				var got strings.Builder
+				for x := range code.Size {
+					if code.Dark(x, y) {
+						got.WriteByte('#')
+					} else {
+						got.WriteByte('.')
+					}
+				}
+				if got.String() != row {
+					t.Errorf("row %d:\n got %s\nwant %s", y, got.String(), row)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := Encode(strings.Repeat("a", 2332)); err != ErrTooLong {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+	if _, err := Encode(strings.Repeat("a", 2331)); err != nil {
+		t.Fatalf("version 40 capacity: %v", err)
+	}
+}
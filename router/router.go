@@ -1,6 +1,7 @@
 package router
 
 import (
+	"github.com/guohuiyuan/go-music-api/config"
 	"github.com/guohuiyuan/go-music-api/handler"
 
 	"github.com/gin-gonic/gin"
@@ -11,51 +12,75 @@ import (
 )
 
 func SetupRouter() *gin.Engine {
-	r := gin.Default()
-
-	// 跨域处理 (对齐 server.go 的 corsMiddleware)
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Cache-Control, Content-Language, Content-Type")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	r := gin.New()
+	// 请求 ID 需最先分配，之后的访问日志与上游调用日志都依赖它关联；
+	// 按路由模板统计请求数与耗时，Recovery 在最内层，panic 的请求也记为 500
+	r.Use(handler.RequestID(), handler.RequestLog(), handler.Metrics(), gin.Recovery())
+
+	admin := handler.RequireRole(config.RoleAdmin)
+	client := handler.RequireRole(config.RoleClient)
+	user := handler.RequireRole(config.RoleUser)
+
+	// 按 API Key 或客户端 IP 限流，搜索、串流与扫码登录分别计算额度
+	searchLimit := handler.RateLimit(config.C.RateLimit.Search)
+	streamLimit := handler.RateLimit(config.C.RateLimit.Stream)
+	qrLimit := handler.RateLimit(config.C.RateLimit.QRLogin)
+	loginLimit := handler.RateLimit(config.C.RateLimit.Login)
 
 	// Swagger UI
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Prometheus 指标，配置了 API Key 时与系统接口一样需要 client 角色
+	r.GET("/metrics", handler.Auth(), client, handler.GetMetrics)
+
 	// ==========================================
 	// 标准化 API 路由 (推荐外部项目接入使用)
 	// ==========================================
-	api := r.Group("/api/v1")
+	// 跨域策略按路由组配置；API Key 鉴权识别调用方角色，具体权限由各路由组声明；
+	// 调用方可通过 X-Music-Cookie-<source> 请求头使用自己的平台 Cookie
+	api := r.Group("/api/v1", handler.CORS(config.C.CORS.API), handler.Auth(), handler.CookieOverride())
 	{
-		// 1. 系统配置
-		sys := api.Group("/system")
+		api.OPTIONS("/*path", handler.Preflight)
+
+		// 存活检查无需 API Key
+		api.GET("/system/health", handler.Health)
+
+		// 1. 系统配置，需要 API Key；开放注册时任何人都能获得用户令牌，因此用户令牌只能使用扫码登录
+		sys := api.Group("/system", client)
+		{
+			sys.GET("/cookies", handler.GetCookies) // 非管理员返回脱敏 Cookie
+			sys.POST("/cookies", admin, handler.SetCookies)
+			sys.GET("/cookies/status", handler.GetCookieStatus)
+			sys.GET("/cookies/accounts", handler.GetCookieAccounts)
+			sys.POST("/cookies/accounts/:source", admin, handler.AddCookieAccount)
+			sys.PUT("/cookies/accounts/:source/:id", admin, handler.LabelCookieAccount)
+			sys.DELETE("/cookies/accounts/:source/:id", admin, handler.RemoveCookieAccount)
+			sys.GET("/qr_login/sessions", admin, handler.GetQRLoginSessions)
+			sys.GET("/health/sources", handler.GetSourceHealth) // 各平台合成探测结果
+			sys.GET("/egress", handler.GetEgress)               // 各平台出站路径(直连或代理)
+		}
+		qr := api.Group("/system/qr_login", user)
 		{
-			sys.GET("/cookies", handler.GetCookies)
-			sys.POST("/cookies", handler.SetCookies)
-			sys.GET("/qr_login/sources", handler.GetQRLoginSources)
-			sys.POST("/qr_login/:source", handler.CreateQRLogin)
-			sys.GET("/qr_login/:source", handler.CheckQRLogin)
+			qr.GET("/sources", handler.GetQRLoginSources)
+			qr.POST("/:source", qrLimit, handler.CreateQRLogin)
+			qr.GET("/:source", qrLimit, handler.CheckQRLogin) // 结果按调用方存入个人保险箱或全局账号池
+			qr.GET("/:source/image", handler.GetQRLoginImage)
+			qr.GET("/:source/events", handler.GetQRLoginEvents) // SSE 推送状态变化，替代客户端轮询
 		}
 
 		// 2. 单曲相关 (Music)
 		music := api.Group("/music")
 		{
-			music.GET("/search", handler.UnifiedSearch)         // 综合搜索(支持链接解析与多源)
-			music.GET("/url", handler.GetMusicUrl)              // 获取音频直链
-			music.GET("/stream", handler.StreamMusic)           // 代理音频流(含soda解密) / 下载音频
-			music.GET("/inspect", handler.InspectMusic)         // 探测音频大小与码率
-			music.GET("/switch", handler.SwitchSource)          // 智能切换可用音源
-			music.GET("/lyric", handler.GetLyric)               // 获取 JSON 格式歌词
-			music.GET("/lyric/file", handler.DownloadLyricFile) // 下载 .lrc 歌词文件
-			music.GET("/cover", handler.ProxyCover)             // 代理/下载封面图防盗链
+			music.GET("/search", searchLimit, handler.UnifiedSearch)    // 综合搜索(支持链接解析与多源)
+			music.GET("/url", handler.GetMusicUrl)                      // 获取音频直链
+			music.GET("/stream", streamLimit, handler.StreamMusic)      // 代理音频流(含soda解密) / 下载音频
+			music.GET("/inspect", handler.InspectMusic)                 // 探测音频大小与码率
+			music.GET("/switch", searchLimit, handler.SwitchSource)     // 智能切换可用音源
+			music.GET("/lyric", handler.GetLyric)                       // 获取 JSON 格式歌词
+			music.GET("/lyric/file", handler.DownloadLyricFile)         // 下载歌词文件(支持 lrc/srt/vtt/ass/ttml)
+			music.GET("/lyric/offset", handler.GetLyricOffset)          // 获取已保存的歌词偏移校正
+			music.POST("/lyric/offset", client, handler.SetLyricOffset) // 保存歌词偏移校正(需 client 角色)
+			music.GET("/cover", handler.ProxyCover)                     // 代理/下载封面图防盗链
 		}
 
 		// 3. 歌单相关 (Playlist)
@@ -72,6 +97,22 @@ func SetupRouter() *gin.Engine {
 		{
 			album.GET("/detail", handler.GetAlbumDetail)
 		}
+
+		// 4. 用户与个人 Cookie 保险箱
+		users := api.Group("/users")
+		{
+			users.POST("/register", loginLimit, handler.RegisterUser)
+			users.POST("/login", loginLimit, handler.LoginUser)
+
+			me := users.Group("/me", handler.RequireUser())
+			{
+				me.GET("", handler.GetCurrentUser)
+				me.POST("/tokens", handler.CreateUserToken)
+				me.DELETE("/tokens/:id", handler.RevokeUserToken)
+				me.GET("/cookies", handler.GetUserCookies)
+				me.POST("/cookies", handler.SetUserCookies)
+			}
+		}
 	}
 
 	// ==========================================
@@ -79,28 +120,57 @@ func SetupRouter() *gin.Engine {
 	// ==========================================
 	// 改组路由完全模拟了原 server.go 暴露的接口路径，并复用上述增强版 handler。
 	// 直接挂载即可无缝衔接原有的网页前端。
-	compat := r.Group("/music")
+	// 兼容组使用独立的宽松跨域策略；出错时沿用原有响应格式，不使用 /api/v1 的错误码目录。
+	compat := r.Group("/music", handler.CORS(config.C.CORS.Compat), handler.Auth(), handler.CookieOverride(), handler.CompatMode())
 	{
-		compat.GET("/cookies", handler.GetCookies)
-		compat.POST("/cookies", handler.SetCookies)
+		compat.OPTIONS("/*path", handler.Preflight)
+
+		compat.GET("/cookies", client, handler.GetCookies)
+		compat.POST("/cookies", admin, handler.SetCookies)
 		compat.GET("/qr_login/sources", handler.GetQRLoginSources)
-		compat.POST("/qr_login/:source", handler.CreateQRLogin)
-		compat.GET("/qr_login/:source", handler.CheckQRLogin)
+		compat.POST("/qr_login/:source", user, qrLimit, handler.CreateQRLogin) // 权限与 /api/v1/system/qr_login 一致
+		compat.GET("/qr_login/:source", user, qrLimit, handler.CheckQRLogin)
 
-		compat.GET("/search", handler.UnifiedSearch)            // 对应 server.go 的 /search
-		compat.GET("/playlist", handler.GetPlaylistDetail)      // 对应 server.go 的 /playlist
-		compat.GET("/album", handler.GetAlbumDetail)            // 对应 server.go 的 /album
-		compat.GET("/recommend", handler.GetRecommendPlaylists) // 对应 server.go 的 /recommend
+		compat.GET("/search", searchLimit, handler.UnifiedSearch) // 对应 server.go 的 /search
+		compat.GET("/playlist", handler.GetPlaylistDetail)        // 对应 server.go 的 /playlist
+		compat.GET("/album", handler.GetAlbumDetail)              // 对应 server.go 的 /album
+		compat.GET("/recommend", handler.GetRecommendPlaylists)   // 对应 server.go 的 /recommend
 		compat.GET("/playlist_categories", handler.GetPlaylistCategories)
 		compat.GET("/category_playlists", handler.GetCategoryPlaylists)
 		compat.GET("/user_playlists", handler.GetUserPlaylists)
 
-		compat.GET("/inspect", handler.InspectMusic)           // 对应 server.go 的 /inspect
-		compat.GET("/switch_source", handler.SwitchSource)     // 对应 server.go 的 /switch_source
-		compat.GET("/download", handler.StreamMusic)           // 对应 server.go 的 /download
-		compat.GET("/download_lrc", handler.DownloadLyricFile) // 对应 server.go 的 /download_lrc
-		compat.GET("/download_cover", handler.ProxyCover)      // 对应 server.go 的 /download_cover
-		compat.GET("/lyric", handler.GetLyricText)             // 对应 server.go 的 /lyric (纯文本返回)
+		compat.GET("/inspect", handler.InspectMusic)                    // 对应 server.go 的 /inspect
+		compat.GET("/switch_source", searchLimit, handler.SwitchSource) // 对应 server.go 的 /switch_source
+		compat.GET("/download", streamLimit, handler.StreamMusic)       // 对应 server.go 的 /download
+		compat.GET("/download_lrc", handler.DownloadLyricFile)          // 对应 server.go 的 /download_lrc
+		compat.GET("/download_cover", handler.ProxyCover)               // 对应 server.go 的 /download_cover
+		compat.GET("/lyric", handler.GetLyricText)                      // 对应 server.go 的 /lyric (纯文本返回)
+	}
+
+	// ==========================================
+	// Subsonic 兼容路由组
+	// ==========================================
+	// 供 DSub、Feishin 等 Subsonic 客户端直接接入，按 Subsonic 协议的 u/p 或 u/t/s 参数鉴权，
+	// 每个接口同时注册 .view 后缀并接受 POST 表单。
+	rest := r.Group("/rest", handler.CORS(config.C.CORS.API), handler.SubsonicAuth(), handler.CookieOverride())
+	{
+		rest.OPTIONS("/*path", handler.Preflight)
+
+		subsonic := func(name string, handlers ...gin.HandlerFunc) {
+			methods := []string{"GET", "POST"}
+			rest.Match(methods, "/"+name, handlers...)
+			rest.Match(methods, "/"+name+".view", handlers...)
+		}
+		subsonic("ping", handler.SubsonicPing)
+		subsonic("getLicense", handler.SubsonicGetLicense)
+		subsonic("search3", searchLimit, handler.SubsonicSearch3)
+		subsonic("getSong", handler.SubsonicGetSong)
+		subsonic("stream", streamLimit, handler.SubsonicStream)
+		subsonic("getCoverArt", handler.SubsonicGetCoverArt)
+		subsonic("getLyrics", handler.SubsonicGetLyrics)
+		subsonic("getPlaylists", handler.SubsonicGetPlaylists)
+		subsonic("getPlaylist", handler.SubsonicGetPlaylist)
+		subsonic("getAlbum", handler.SubsonicGetAlbum)
 	}
 
 	return r
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/metrics"
+	"github.com/guohuiyuan/go-music-api/service"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSystemRoutesRejectUserTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Chdir(t.TempDir())
+	if err := service.US.Load(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { service.US.Close() })
+	if _, err := service.US.Register("alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	userToken, _, err := service.US.CreateToken("alice", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := config.C.Auth.Keys
+	t.Cleanup(func() { config.C.Auth.Keys = old })
+	config.C.Auth.Keys = []config.APIKey{{Name: "web", Key: "client-key", Role: config.RoleClient}}
+
+	r := SetupRouter()
+	tests := []struct {
+		name   string
+		path   string
+		key    string
+		status int
+	}{
+		{"egress with user token", "/api/v1/system/egress", userToken, 403},
+		{"cookies with user token", "/api/v1/system/cookies", userToken, 403},
+		{"cookie accounts with user token", "/api/v1/system/cookies/accounts", userToken, 403},
+		{"metrics with user token", "/metrics", userToken, 403},
+		{"egress with client key", "/api/v1/system/egress", "client-key", 200},
+		{"qr login sources with user token", "/api/v1/system/qr_login/sources", userToken, 200},
+		{"qr login sources without key", "/api/v1/system/qr_login/sources", "", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestMetricsCountPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+	r.GET("/panic", func(*gin.Context) { panic("boom") })
+	counter := metrics.HTTPRequests.WithLabelValues("/panic", "GET", "500")
+	before := testutil.ToFloat64(counter)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != 500 {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Fatalf("5xx counter increased by %v, want 1", got)
+	}
+}
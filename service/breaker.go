@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/go-music-api/metrics"
+)
+
+// 熔断器状态
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitState 单个平台熔断器的当前状态
+type CircuitState struct {
+	Source   string    `json:"source"`
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`           // 当前连续失败次数
+	OpenedAt time.Time `json:"opened_at,omitzero"` // 最近一次熔断的时间
+	RetryAt  time.Time `json:"retry_at,omitzero"`  // 冷却结束、允许试探的时间
+}
+
+type circuit struct {
+	failures int
+	openedAt time.Time
+	open     bool
+	trial    bool // 半开状态下已有试探请求在进行
+}
+
+// CircuitBreakers 按平台统计连续失败，平台不可用时让扇出请求直接跳过它，不再等待超时
+type CircuitBreakers struct {
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+var CB = &CircuitBreakers{circuits: make(map[string]*circuit)}
+
+func circuitCooldown() time.Duration {
+	return time.Duration(config.C.Circuit.CooldownSeconds) * time.Second
+}
+
+// acquire 判断是否放行一次上游调用；熔断冷却期内返回 ErrCircuitOpen，
+// 冷却结束后只放行一个试探请求，trial 为 true 表示本次调用即为试探
+func (b *CircuitBreakers) acquire(source string) (trial bool, err error) {
+	if config.C.Circuit.FailureThreshold < 0 {
+		return false, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[source]
+	if c == nil || !c.open {
+		return false, nil
+	}
+	if c.trial || time.Since(c.openedAt) < circuitCooldown() {
+		return false, NewSourceError(source, ErrCircuitOpen, nil)
+	}
+	c.trial = true
+	return true, nil
+}
+
+// release 未实际访问上游(如被出站限流拒绝)时归还试探名额
+func (b *CircuitBreakers) release(source string, trial bool) {
+	if !trial {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c := b.circuits[source]; c != nil {
+		c.trial = false
+	}
+}
+
+// record 记录一次上游调用的结果。只有超时、限流与上游故障计为失败，
+// 资源不存在、需要登录说明平台仍在正常响应，与成功一样清零计数
+func (b *CircuitBreakers) record(source string, trial bool, err error) {
+	if config.C.Circuit.FailureThreshold < 0 {
+		return
+	}
+	failed := err != nil && circuitFailure(err)
+	b.mu.Lock()
+	c := b.circuits[source]
+	if c == nil {
+		if !failed {
+			b.mu.Unlock()
+			return
+		}
+		c = &circuit{}
+		b.circuits[source] = c
+	}
+	if trial {
+		c.trial = false
+	}
+	wasOpen := c.open
+	if !failed {
+		c.failures = 0
+		c.open = false
+		b.mu.Unlock()
+		if wasOpen {
+			metrics.CircuitOpen.WithLabelValues(source).Set(0)
+			slog.Info("circuit closed", "source", source)
+		}
+		return
+	}
+	c.failures++
+	opened := false
+	if trial || (!c.open && c.failures >= config.C.Circuit.FailureThreshold) {
+		c.open = true
+		c.openedAt = time.Now()
+		opened = true
+	}
+	failures := c.failures
+	b.mu.Unlock()
+	if opened {
+		metrics.CircuitOpen.WithLabelValues(source).Set(1)
+		slog.Warn("circuit opened", "source", source, "failures", failures, "cooldown_seconds", config.C.Circuit.CooldownSeconds, "error", err.Error())
+	}
+}
+
+func circuitFailure(err error) bool {
+	switch classifyError(err) {
+	case ErrTimeout, ErrRateLimited, ErrUpstream:
+		return true
+	}
+	return false
+}
+
+// Open 平台当前是否处于熔断冷却期，扇出请求可据此提前跳过
+func (b *CircuitBreakers) Open(source string) bool {
+	_, err := b.peek(source)
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+func (b *CircuitBreakers) peek(source string) (CircuitState, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := CircuitState{Source: source, State: CircuitClosed}
+	c := b.circuits[source]
+	if c == nil {
+		return state, nil
+	}
+	state.Failures = c.failures
+	if !c.open {
+		return state, nil
+	}
+	state.OpenedAt = c.openedAt
+	state.RetryAt = c.openedAt.Add(circuitCooldown())
+	if c.trial || time.Now().Before(state.RetryAt) {
+		state.State = CircuitOpen
+		return state, NewSourceError(source, ErrCircuitOpen, nil)
+	}
+	state.State = CircuitHalfOpen
+	return state, nil
+}
+
+// State 返回平台熔断器的当前状态
+func (b *CircuitBreakers) State(source string) CircuitState {
+	state, _ := b.peek(source)
+	return state
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+func newTestBreakers(t *testing.T) *CircuitBreakers {
+	t.Helper()
+	old := config.C.Circuit
+	t.Cleanup(func() { config.C.Circuit = old })
+	config.C.Circuit = config.CircuitBreakerConfig{FailureThreshold: 2, CooldownSeconds: 30}
+	return &CircuitBreakers{circuits: make(map[string]*circuit)}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	timeout := NewSourceError("qq", ErrTimeout, nil)
+	notFound := NewSourceError("qq", ErrNotFound, nil)
+	tests := []struct {
+		name    string
+		results []error
+		want    string
+	}{
+		{"below threshold", []error{timeout}, CircuitClosed},
+		{"threshold reached", []error{timeout, timeout}, CircuitOpen},
+		{"success resets count", []error{timeout, nil, timeout}, CircuitClosed},
+		{"not found resets count", []error{timeout, notFound, timeout}, CircuitClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreakers(t)
+			for _, err := range tt.results {
+				b.record("qq", false, err)
+			}
+			if got := b.State("qq").State; got != tt.want {
+				t.Fatalf("state = %s, want %s", got, tt.want)
+			}
+			_, err := b.acquire("qq")
+			if open := errors.Is(err, ErrCircuitOpen); open != (tt.want == CircuitOpen) {
+				t.Fatalf("acquire err = %v", err)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name  string
+		trial error
+		want  string
+	}{
+		{"trial succeeds", nil, CircuitClosed},
+		{"trial fails", NewSourceError("qq", ErrUpstream, nil), CircuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreakers(t)
+			for range 2 {
+				b.record("qq", false, NewSourceError("qq", ErrTimeout, nil))
+			}
+			b.circuits["qq"].openedAt = time.Now().Add(-time.Minute)
+			if got := b.State("qq").State; got != CircuitHalfOpen {
+				t.Fatalf("state after cooldown = %s", got)
+			}
+
+			trial, err := b.acquire("qq")
+			if err != nil || !trial {
+				t.Fatalf("first acquire = %v, %v; want trial", trial, err)
+			}
+			if _, err := b.acquire("qq"); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("second acquire during trial = %v", err)
+			}
+			b.record("qq", true, tt.trial)
+			if got := b.State("qq").State; got != tt.want {
+				t.Fatalf("state after trial = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerReleaseTrial(t *testing.T) {
+	b := newTestBreakers(t)
+	for range 2 {
+		b.record("qq", false, NewSourceError("qq", ErrTimeout, nil))
+	}
+	b.circuits["qq"].openedAt = time.Now().Add(-time.Minute)
+	trial, _ := b.acquire("qq")
+	b.release("qq", trial)
+	if trial, err := b.acquire("qq"); err != nil || !trial {
+		t.Fatalf("acquire after release = %v, %v", trial, err)
+	}
+}
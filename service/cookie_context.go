@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"strings"
+)
+
+// Cookies 单次请求自带的各平台 Cookie，键为平台名
+type Cookies map[string]string
+
+type cookiesContextKey struct{}
+
+// WithCookies 将请求自带的 Cookie 放入 context，工厂函数会优先使用它们而不是全局账号池
+func WithCookies(ctx context.Context, cookies Cookies) context.Context {
+	if len(cookies) == 0 {
+		return ctx
+	}
+	merged := make(Cookies, len(cookies))
+	for source, cookie := range CookiesFromContext(ctx) {
+		merged[source] = cookie
+	}
+	for source, cookie := range cookies {
+		if source, cookie = strings.TrimSpace(source), strings.TrimSpace(cookie); source != "" && cookie != "" {
+			merged[source] = cookie
+		}
+	}
+	return context.WithValue(ctx, cookiesContextKey{}, merged)
+}
+
+// CookiesFromContext 返回 context 中请求自带的 Cookie
+func CookiesFromContext(ctx context.Context) Cookies {
+	cookies, _ := ctx.Value(cookiesContextKey{}).(Cookies)
+	return cookies
+}
+
+// HasCookie 判断访问平台时是否有可用的 Cookie，不会推进账号池的轮换
+func HasCookie(ctx context.Context, source string) bool {
+	return CookiesFromContext(ctx)[source] != "" || CM.Available(source)
+}
+
+// CookieFor 返回本次请求访问指定平台时使用的 Cookie：请求自带的优先，否则从全局账号池选取
+func CookieFor(ctx context.Context, source string) string {
+	if cookie := CookiesFromContext(ctx)[source]; cookie != "" {
+		return cookie
+	}
+	return CM.Get(source)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// Cookie 校验状态
+const (
+	CookieStatusPending     = "pending"      // 尚未校验
+	CookieStatusValid       = "valid"        // 最近一次校验通过
+	CookieStatusInvalid     = "invalid"      // 上游返回需要登录，Cookie 已失效
+	CookieStatusExpired     = "expired"      // 按 Cookie 内的过期字段判断已过期
+	CookieStatusError       = "error"        // 校验请求失败，无法判断
+	CookieStatusCircuitOpen = "circuit_open" // 校验请求失败且平台处于熔断，多半是平台故障而非账号问题
+	CookieStatusUnchecked   = "unchecked"    // 平台不支持在线校验，仅解析过期时间
+)
+
+// CookieStatus 单个账号的 Cookie 校验结果
+type CookieStatus struct {
+	Source       string    `json:"source"`
+	Account      string    `json:"account"`
+	Label        string    `json:"label,omitempty"`
+	Status       string    `json:"status"`
+	Message      string    `json:"message,omitempty"`
+	LastChecked  time.Time `json:"last_checked,omitzero"`
+	LastVerified time.Time `json:"last_verified,omitzero"` // 最近一次校验通过的时间
+	ExpiresAt    time.Time `json:"expires_at,omitzero"`    // 从 Cookie 字段解析出的过期时间
+	Quarantined  bool      `json:"quarantined"`
+}
+
+// CookieHealthChecker 定期通过轻量的账号接口校验各平台 Cookie 是否仍然有效
+type CookieHealthChecker struct {
+	mu     sync.RWMutex
+	status map[string]*CookieStatus // 键为 source/account
+	once   sync.Once
+}
+
+var CHC = &CookieHealthChecker{status: make(map[string]*CookieStatus)}
+
+// Start 启动后台校验，间隔由 cookies.health_check_minutes 配置，负数表示关闭
+func (h *CookieHealthChecker) Start() {
+	minutes := config.C.Cookies.HealthCheckMinutes
+	if minutes < 0 {
+		return
+	}
+	h.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Duration(minutes) * time.Minute)
+			defer ticker.Stop()
+			for {
+				h.CheckAll()
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// CheckAll 立即校验账号池中的全部账号
+func (h *CookieHealthChecker) CheckAll() {
+	for source, accounts := range CM.Accounts(false) {
+		for _, account := range accounts {
+			h.check(source, account)
+		}
+	}
+}
+
+func (h *CookieHealthChecker) check(source string, account CookieAccount) {
+	now := time.Now()
+	result := CookieStatus{
+		Source:      source,
+		Account:     account.ID,
+		LastChecked: now,
+		ExpiresAt:   CookieExpiry(source, account.Cookie),
+	}
+
+	switch {
+	case !result.ExpiresAt.IsZero() && now.After(result.ExpiresAt):
+		result.Status = CookieStatusExpired
+		result.Message = "cookie expired at " + result.ExpiresAt.Format(time.RFC3339)
+	case userPlaylistsFunc(source, account.Cookie) == nil:
+		result.Status = CookieStatusUnchecked
+	default:
+		err := verifyCookie(source, account.Cookie)
+		switch {
+		case err == nil:
+			result.Status = CookieStatusValid
+			result.LastVerified = now
+		case errors.Is(WrapSourceError(source, err), ErrAuthRequired):
+			result.Status = CookieStatusInvalid
+			result.Message = err.Error()
+		case CB.Open(source):
+			result.Status = CookieStatusCircuitOpen
+			result.Message = err.Error()
+		default:
+			result.Status = CookieStatusError
+			result.Message = err.Error()
+		}
+	}
+
+	key := source + "/" + account.ID
+	h.mu.Lock()
+	prev := h.status[key]
+	if prev != nil && result.LastVerified.IsZero() {
+		result.LastVerified = prev.LastVerified
+	}
+	h.status[key] = &result
+	h.mu.Unlock()
+
+	failed := result.Status == CookieStatusInvalid || result.Status == CookieStatusExpired
+	if failed && (prev == nil || prev.Status != result.Status) {
+		slog.Warn("cookie check failed", "source", source, "account", account.ID, "status", result.Status, "message", result.Message)
+	}
+}
+
+// verifyCookie 以账号的 Cookie 请求一次个人歌单。校验是诊断调用：不重试、不经熔断与出站限流，
+// 结果也不计入账号池，避免校验本身让账号被隔离。平台库不接受 context，超时后不再等待，请求在后台自行结束
+func verifyCookie(source, cookie string) error {
+	ctx, cancel := context.WithTimeout(withDiagnostic(context.Background()), seconds(config.C.HTTP.ProbeTimeoutSeconds))
+	defer cancel()
+	fn := wrap2(upstreamCall{ctx: ctx, source: source, capability: CapUserPlaylists, cookie: cookie}, userPlaylistsFunc(source, cookie))
+	done := make(chan error, 1)
+	go func() {
+		_, err := fn(1, 1)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return NewSourceError(source, ErrTimeout, ctx.Err())
+	}
+}
+
+// Statuses 返回当前账号池中每个账号的最近一次校验结果
+func (h *CookieHealthChecker) Statuses() []CookieStatus {
+	now := time.Now()
+	pools := CM.Accounts(false)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var result []CookieStatus
+	for _, source := range GetAllSourceNames() {
+		for _, account := range pools[source] {
+			status := CookieStatus{
+				Source:    source,
+				Account:   account.ID,
+				Status:    CookieStatusPending,
+				ExpiresAt: CookieExpiry(source, account.Cookie),
+			}
+			if s := h.status[source+"/"+account.ID]; s != nil {
+				status = *s
+			}
+			status.Label = account.Label
+			status.Quarantined = account.quarantined(now)
+			result = append(result, status)
+		}
+	}
+	return result
+}
+
+// CookieExpiry 从 Cookie 中解析已知的过期字段，无法判断时返回零值：
+// bilibili 的 SESSDATA、QQ 音乐的 psrf_*_expiresAt，以及从 Set-Cookie 复制来的 Expires。
+func CookieExpiry(source string, cookie string) time.Time {
+	var expiry time.Time
+	earliest := func(t time.Time) {
+		if !t.IsZero() && (expiry.IsZero() || t.Before(expiry)) {
+			expiry = t
+		}
+	}
+	for _, part := range strings.Split(cookie, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.TrimSpace(name)
+		switch {
+		case source == "bilibili" && name == "SESSDATA":
+			// SESSDATA 形如 xxx,1735660800,xxx*xx，第二段为过期时间戳
+			if decoded, err := url.QueryUnescape(value); err == nil {
+				if fields := strings.Split(decoded, ","); len(fields) >= 2 {
+					earliest(unixSeconds(fields[1]))
+				}
+			}
+		case strings.HasPrefix(name, "psrf_") && strings.HasSuffix(name, "expiresAt"):
+			earliest(unixSeconds(value))
+		case strings.EqualFold(name, "expires"):
+			if t, err := http.ParseTime(value); err == nil {
+				earliest(t)
+			}
+		}
+	}
+	return expiry
+}
+
+func unixSeconds(s string) time.Time {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	if n > 1e12 {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
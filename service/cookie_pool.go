@@ -0,0 +1,232 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// DefaultCookieAccount 通过 SetAll、扫码登录或旧版 cookies.json 写入的账号 ID
+const DefaultCookieAccount = "default"
+
+// CookieAccount 账号池中的一个平台账号
+type CookieAccount struct {
+	ID               string    `json:"id"`
+	Label            string    `json:"label,omitempty"`
+	Cookie           string    `json:"cookie"`
+	CreatedAt        time.Time `json:"created_at,omitzero"`
+	LastUsed         time.Time `json:"last_used,omitzero"`
+	AuthFailures     int       `json:"auth_failures,omitempty"`    // 连续鉴权失败次数
+	QuarantinedUntil time.Time `json:"quarantined_until,omitzero"` // 隔离截止时间，期间不参与选取
+}
+
+func newCookieAccount(id, label, cookie string) *CookieAccount {
+	return &CookieAccount{ID: id, Label: label, Cookie: cookie, CreatedAt: time.Now()}
+}
+
+func (a *CookieAccount) quarantined(now time.Time) bool {
+	return now.Before(a.QuarantinedUntil)
+}
+
+func (a *CookieAccount) resetHealth() {
+	a.AuthFailures = 0
+	a.QuarantinedUntil = time.Time{}
+}
+
+// decodePools 解析 Cookie 文件，兼容旧版 {"source": "cookie"} 格式
+func (m *CookieManager) decodePools(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	pools := make(map[string][]*CookieAccount, len(raw))
+	for source, value := range raw {
+		var cookie string
+		if json.Unmarshal(value, &cookie) == nil {
+			if cookie = strings.TrimSpace(cookie); cookie != "" {
+				pools[source] = []*CookieAccount{newCookieAccount(DefaultCookieAccount, "", cookie)}
+			}
+			continue
+		}
+		var accounts []*CookieAccount
+		if err := json.Unmarshal(value, &accounts); err != nil {
+			return fmt.Errorf("source %s: %w", source, err)
+		}
+		if len(accounts) > 0 {
+			pools[source] = accounts
+		}
+	}
+	m.pools = pools
+	return nil
+}
+
+// pick 选取可用账号并记录使用时间，调用方需持有写锁
+func (m *CookieManager) pick(source string, now time.Time) *CookieAccount {
+	var available []*CookieAccount
+	for _, account := range m.pools[source] {
+		if !account.quarantined(now) {
+			available = append(available, account)
+		}
+	}
+	if len(available) == 0 {
+		return nil
+	}
+
+	var account *CookieAccount
+	if config.C.Cookies.Selection == config.CookieSelectLRU {
+		account = available[0]
+		for _, a := range available[1:] {
+			if a.LastUsed.Before(account.LastUsed) {
+				account = a
+			}
+		}
+	} else {
+		account = available[m.next[source]%len(available)]
+		m.next[source]++
+	}
+	account.LastUsed = now
+	return account
+}
+
+func (m *CookieManager) find(source, id string) *CookieAccount {
+	for _, account := range m.pools[source] {
+		if account.ID == id {
+			return account
+		}
+	}
+	return nil
+}
+
+// report 根据上游调用结果更新账号健康状态，连续鉴权失败达到阈值时隔离账号
+func (m *CookieManager) report(source, cookie string, callErr error) {
+	authFailed := callErr != nil && errors.Is(WrapSourceError(source, callErr), ErrAuthRequired)
+	if callErr != nil && !authFailed {
+		return
+	}
+
+	m.mu.Lock()
+	var account *CookieAccount
+	for _, a := range m.pools[source] {
+		if a.Cookie == cookie {
+			account = a
+			break
+		}
+	}
+	if account == nil || (!authFailed && account.AuthFailures == 0) {
+		m.mu.Unlock()
+		return
+	}
+	if !authFailed {
+		account.resetHealth()
+		m.mu.Unlock()
+		return
+	}
+	account.AuthFailures++
+	quarantine := account.AuthFailures >= config.C.Cookies.QuarantineAfter && !account.quarantined(time.Now())
+	if quarantine {
+		account.QuarantinedUntil = time.Now().Add(time.Duration(config.C.Cookies.QuarantineMinutes) * time.Minute)
+	}
+	id := account.ID
+	m.mu.Unlock()
+
+	if quarantine {
+		slog.Warn("cookie account quarantined", "source", source, "account", id, "auth_failures", config.C.Cookies.QuarantineAfter)
+		if err := m.Save(); err != nil {
+			slog.Error("save cookies failed", "error", err)
+		}
+	}
+}
+
+// Accounts 返回各平台账号池的副本，masked 为 true 时 Cookie 脱敏
+func (m *CookieManager) Accounts(masked bool) map[string][]CookieAccount {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[string][]CookieAccount, len(m.pools))
+	for source, accounts := range m.pools {
+		list := make([]CookieAccount, 0, len(accounts))
+		for _, account := range accounts {
+			a := *account
+			if masked {
+				a.Cookie = MaskCookie(a.Cookie)
+			}
+			list = append(list, a)
+		}
+		result[source] = list
+	}
+	return result
+}
+
+// AddAccount 向平台账号池追加账号，id 为空时自动生成
+func (m *CookieManager) AddAccount(source, id, label, cookie string) (CookieAccount, error) {
+	source = strings.TrimSpace(source)
+	cookie = strings.TrimSpace(cookie)
+	id = strings.TrimSpace(id)
+	if source == "" || cookie == "" {
+		return CookieAccount{}, errors.New("source and cookie are required")
+	}
+	if id == "" {
+		id = newCookieAccountID()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.find(source, id) != nil {
+		return CookieAccount{}, fmt.Errorf("account %s already exists", id)
+	}
+	account := newCookieAccount(id, strings.TrimSpace(label), cookie)
+	m.pools[source] = append(m.pools[source], account)
+	return *account, nil
+}
+
+// LabelAccount 修改账号备注
+func (m *CookieManager) LabelAccount(source, id, label string) (CookieAccount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	account := m.find(source, id)
+	if account == nil {
+		return CookieAccount{}, NewSourceError(source, ErrNotFound, fmt.Errorf("account %s not found", id))
+	}
+	account.Label = strings.TrimSpace(label)
+	return *account, nil
+}
+
+// RemoveAccount 从平台账号池移除账号
+func (m *CookieManager) RemoveAccount(source, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.removeLocked(source, id) {
+		return NewSourceError(source, ErrNotFound, fmt.Errorf("account %s not found", id))
+	}
+	return nil
+}
+
+// removeLocked 移除账号，账号池为空时删除该平台，调用方需持有写锁
+func (m *CookieManager) removeLocked(source, id string) bool {
+	accounts := m.pools[source]
+	for i, account := range accounts {
+		if account.ID != id {
+			continue
+		}
+		accounts = append(accounts[:i:i], accounts[i+1:]...)
+		if len(accounts) == 0 {
+			delete(m.pools, source)
+		} else {
+			m.pools[source] = accounts
+		}
+		return true
+	}
+	return false
+}
+
+func newCookieAccountID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func newTestCookieManager(t *testing.T) *CookieManager {
+	t.Helper()
+	old := CM
+	t.Cleanup(func() { CM = old })
+	CM = &CookieManager{pools: make(map[string][]*CookieAccount), next: make(map[string]int)}
+	return CM
+}
+
+func TestFactoryDoesNotAdvanceRotation(t *testing.T) {
+	m := newTestCookieManager(t)
+	m.SetAll(map[string]string{"netease": "a=1"})
+	if _, err := m.AddAccount("netease", "second", "", "a=2"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for range 3 {
+		if GetLyricFunc(ctx, "netease") == nil || !HasCookie(ctx, "netease") {
+			t.Fatal("expected lyric support and an available cookie")
+		}
+	}
+	if m.next["netease"] != 0 {
+		t.Fatalf("factory calls advanced rotation to %d", m.next["netease"])
+	}
+	if first, second := m.Get("netease"), m.Get("netease"); first == second {
+		t.Errorf("round robin returned %q twice", first)
+	}
+}
+
+func TestSetAllEmptyKeepsNamedAccounts(t *testing.T) {
+	m := newTestCookieManager(t)
+	m.SetAll(map[string]string{"qq": "a=1"})
+	if _, err := m.AddAccount("qq", "backup", "", "b=2"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetAll(map[string]string{"qq": ""})
+	accounts := m.pools["qq"]
+	if len(accounts) != 1 || accounts[0].Cookie != "b=2" {
+		t.Fatalf("expected only the named account to remain, got %+v", accounts)
+	}
+
+	m.SetAll(map[string]string{"kugou": "c=3"})
+	m.SetAll(map[string]string{"kugou": ""})
+	if _, ok := m.pools["kugou"]; ok {
+		t.Error("expected empty pool to be removed")
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	cookieFileCipher  = "AES-256-GCM"
+	cookieFileKDF     = "scrypt"
+	cookieFileVersion = 2
+)
+
+// scrypt 参数，派生一次约需几十毫秒；派生结果按口令与盐缓存
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 16
+)
+
+// encryptedCookieFile 加密后的 Cookie 文件格式，Data 为密文的 base64
+type encryptedCookieFile struct {
+	Version int    `json:"version"`
+	Cipher  string `json:"cipher"`
+	KDF     string `json:"kdf,omitempty"`
+	Salt    string `json:"salt,omitempty"`
+	Nonce   string `json:"nonce"`
+	Data    string `json:"data"`
+}
+
+var errCookieKeyMissing = errors.New("cookie file is encrypted but no encryption key is configured")
+
+// cookieKeys 缓存 scrypt 派生的密钥；每个口令复用最近一次使用的盐，避免每次保存都重新派生
+var cookieKeys = struct {
+	sync.Mutex
+	salts   map[string][]byte
+	derived map[string][]byte
+}{salts: make(map[string][]byte), derived: make(map[string][]byte)}
+
+func deriveCookieKey(secret, salt []byte) ([]byte, error) {
+	cookieKeys.Lock()
+	defer cookieKeys.Unlock()
+	id := string(secret) + "\x00" + string(salt)
+	if key, ok := cookieKeys.derived[id]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key(secret, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	cookieKeys.derived[id] = key
+	cookieKeys.salts[string(secret)] = salt
+	return key, nil
+}
+
+// cookieSalt 返回口令最近使用的盐，没有时生成新的随机盐
+func cookieSalt(secret []byte) ([]byte, error) {
+	cookieKeys.Lock()
+	salt, ok := cookieKeys.salts[string(secret)]
+	cookieKeys.Unlock()
+	if ok {
+		return salt, nil
+	}
+	salt = make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// sealCookieFile 使用 scrypt 从口令派生密钥，再以 AES-GCM 加密 Cookie 数据；secret 为空时原样返回明文
+func sealCookieFile(plain, secret []byte) ([]byte, error) {
+	if len(secret) == 0 {
+		return plain, nil
+	}
+	salt, err := cookieSalt(secret)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveCookieKey(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newCookieCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(encryptedCookieFile{
+		Version: cookieFileVersion,
+		Cipher:  cookieFileCipher,
+		KDF:     cookieFileKDF,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Data:    base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plain, nil)),
+	}, "", "  ")
+}
+
+// openCookieFile 解密 Cookie 文件。旧版明文文件原样返回；current 表示文件已加密，
+// 为 false 时调用方应在配置了口令的情况下重新保存
+func openCookieFile(data, secret []byte) (plain []byte, current bool, err error) {
+	var envelope encryptedCookieFile
+	if json.Unmarshal(data, &envelope) != nil || envelope.Cipher == "" || envelope.Data == "" {
+		return data, false, nil
+	}
+	if envelope.Cipher != cookieFileCipher {
+		return nil, false, errors.New("unsupported cookie file cipher: " + envelope.Cipher)
+	}
+	if len(secret) == 0 {
+		return nil, false, errCookieKeyMissing
+	}
+	if envelope.KDF != cookieFileKDF {
+		return nil, false, errors.New("unsupported cookie file kdf: " + envelope.KDF)
+	}
+	salt, err := base64.StdEncoding.DecodeString(envelope.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, false, errors.New("invalid cookie file salt")
+	}
+	key, err := deriveCookieKey(secret, salt)
+	if err != nil {
+		return nil, false, err
+	}
+	gcm, err := newCookieCipher(key)
+	if err != nil {
+		return nil, false, err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil || len(nonce) != gcm.NonceSize() {
+		return nil, false, errors.New("invalid cookie file nonce")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		return nil, false, err
+	}
+	plain, err = gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, false, errors.New("decrypt cookie file: wrong key or corrupted data")
+	}
+	return plain, true, nil
+}
+
+func newCookieCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic 先写入同目录临时文件再重命名，避免写入中断导致文件损坏；
+// 目标文件无法被替换(EBUSY、EXDEV)时退回原地写入
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	err = os.Rename(tmpName, path)
+	if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+		// 以单文件方式挂载到容器中时无法替换该文件，退回原地覆盖写入
+		return writeFileInPlace(path, data, perm)
+	}
+	return err
+}
+
+func writeFileInPlace(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
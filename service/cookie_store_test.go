@@ -0,0 +1,56 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCookieFileRoundTrip(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+	sealed, err := sealCookieFile([]byte(`{"qq":[]}`), secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope encryptedCookieFile
+	if err := json.Unmarshal(sealed, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Version != cookieFileVersion || envelope.KDF != cookieFileKDF || envelope.Salt == "" {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+
+	plain, current, err := openCookieFile(sealed, secret)
+	if err != nil || !current || string(plain) != `{"qq":[]}` {
+		t.Fatalf("open = %q, %v, %v", plain, current, err)
+	}
+	if _, _, err := openCookieFile(sealed, []byte("wrong")); err == nil {
+		t.Error("expected error for wrong secret")
+	}
+	if _, _, err := openCookieFile(sealed, nil); err != errCookieKeyMissing {
+		t.Errorf("expected errCookieKeyMissing, got %v", err)
+	}
+}
+
+func TestCookieFilePlaintextMigration(t *testing.T) {
+	secret := []byte("migrate")
+	plain, current, err := openCookieFile([]byte(`{"qq":[]}`), secret)
+	if err != nil || current || string(plain) != `{"qq":[]}` {
+		t.Fatalf("plaintext open = %q, %v, %v", plain, current, err)
+	}
+
+	sealed, err := sealCookieFile(plain, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope encryptedCookieFile
+	if err := json.Unmarshal(sealed, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.Version != cookieFileVersion || envelope.KDF != cookieFileKDF {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+	plain, current, err = openCookieFile(sealed, secret)
+	if err != nil || !current || string(plain) != `{"qq":[]}` {
+		t.Fatalf("migrated open = %q, %v, %v", plain, current, err)
+	}
+}
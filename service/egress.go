@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// 出站路径
+const (
+	EgressDirect = "direct"
+	EgressProxy  = "proxy"
+)
+
+// 经出口发出的流量类别
+const (
+	TrafficMedia   = "media"   // 服务自己发起的请求：串流、下载、封面与可播放探测
+	TrafficLibrary = "library" // 平台库发起的接口调用：搜索、获取链接、歌单等
+)
+
+// libraryRouted 记录 http.DefaultTransport 是否已替换为按平台选择代理的 Transport
+var libraryRouted atomic.Bool
+
+// SourceHosts 返回平台的域名，含 proxy.hosts 中追加的部分
+func SourceHosts(source string) []string {
+	return append(slices.Clone(sourceProfiles[source].hosts), config.C.Proxy.Hosts[source]...)
+}
+
+// SourceForHost 按最长后缀匹配返回域名所属平台，如 5sing.kugou.com 属于 fivesing 而非 kugou，
+// 无法识别时返回空串
+func SourceForHost(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	matched, longest := "", 0
+	for _, source := range GetAllSourceNames() {
+		for _, suffix := range SourceHosts(source) {
+			suffix = strings.ToLower(suffix)
+			if (host == suffix || strings.HasSuffix(host, "."+suffix)) && len(suffix) > longest {
+				matched, longest = source, len(suffix)
+			}
+		}
+	}
+	return matched
+}
+
+// egressTransport 按域名识别请求所属平台，再交给该平台出站代理对应的共享 Transport
+type egressTransport struct{}
+
+func (egressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return HTTPClient(SourceForHost(req.URL.Hostname())).Transport.RoundTrip(req)
+}
+
+// RouteDefaultTransport 将 http.DefaultTransport 替换为按平台选择代理的 Transport。
+// 平台库按 Cookie 创建客户端，不接受自定义 Transport，但使用默认 Transport 发起请求，
+// 借此让平台接口调用与音频直链请求走同一出口。
+// 这是进程级的副作用：进程内所有使用默认 Transport 的请求都会经过它，
+// 断言 http.DefaultTransport 为 *http.Transport 的代码也会失效。
+// 配置了任一代理时默认调用，可通过 proxy.route_library_traffic 显式关闭
+func RouteDefaultTransport() {
+	http.DefaultTransport = egressTransport{}
+	libraryRouted.Store(true)
+}
+
+// Egress 平台的出站路径
+type Egress struct {
+	Source  string      `json:"source"`
+	URL     string      `json:"url,omitempty"` // 按 URL 查询时的目标地址
+	Route   string      `json:"route"`         // direct 或 proxy
+	Proxy   string      `json:"proxy,omitempty"`
+	Hosts   []string    `json:"hosts,omitempty"`
+	Library bool        `json:"library"`           // 平台库的接口调用是否也走该出口
+	Traffic []string    `json:"traffic,omitempty"` // 实际经代理发出的流量类别，直连时为空
+	Test    *EgressTest `json:"test,omitempty"`
+}
+
+// EgressTest 经平台出口访问测试地址的结果，收到任意 HTTP 响应即视为连通
+type EgressTest struct {
+	URL        string `json:"url"`
+	OK         bool   `json:"ok"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// EgressFor 返回平台的出站路径，代理地址中的密码会被隐去
+func EgressFor(source string) Egress {
+	e := Egress{Source: source, Route: EgressDirect, Hosts: SourceHosts(source), Library: libraryRouted.Load()}
+	if proxy := config.C.Proxy.For(source); proxy != "" {
+		e.Route = EgressProxy
+		e.Proxy = proxy
+		if u, err := url.Parse(proxy); err == nil {
+			e.Proxy = u.Redacted()
+		}
+		e.Traffic = []string{TrafficMedia}
+		if e.Library {
+			e.Traffic = append(e.Traffic, TrafficLibrary)
+		}
+	}
+	return e
+}
+
+// EgressForURL 返回访问 rawURL 时实际使用的出站路径，域名不属于任何平台时走默认代理
+func EgressForURL(rawURL string) (Egress, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Egress{}, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Egress{}, fmt.Errorf("invalid url %q", rawURL)
+	}
+	e := EgressFor(SourceForHost(u.Hostname()))
+	e.URL = rawURL
+	e.Hosts = nil
+	return e, nil
+}
+
+// TestEgress 经出站路径向测试地址发送带平台请求头的 HEAD 请求，未指定 URL 时访问平台的第一个域名
+func TestEgress(ctx context.Context, e *Egress) {
+	target := e.URL
+	if target == "" && len(e.Hosts) > 0 {
+		target = "https://" + e.Hosts[0] + "/"
+	}
+	if target == "" {
+		return
+	}
+	e.Test = &EgressTest{URL: target}
+	ctx, cancel := context.WithTimeout(ctx, seconds(config.C.HTTP.ProbeTimeoutSeconds))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "HEAD", target, nil)
+	if err != nil {
+		e.Test.Error = err.Error()
+		return
+	}
+	ApplyHeaders(req, e.Source)
+	start := time.Now()
+	resp, err := HTTPClient(e.Source).Do(req)
+	e.Test.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		e.Test.Error = err.Error()
+		return
+	}
+	resp.Body.Close()
+	e.Test.OK = true
+	e.Test.HTTPStatus = resp.StatusCode
+}
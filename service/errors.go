@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// 服务层的错误类型，handler 根据类型映射 HTTP 状态码与错误码
+var (
+	ErrNotFound     = errors.New("resource not found")
+	ErrAuthRequired = errors.New("authentication required")
+	ErrPaidContent  = errors.New("content requires a paid membership")
+	ErrRateLimited  = errors.New("rate limited by upstream")
+	ErrTimeout      = errors.New("upstream timeout")
+	ErrUpstream     = errors.New("upstream failure")
+	ErrCircuitOpen  = errors.New("source temporarily skipped: circuit open")
+)
+
+// SourceError 记录出错的平台与原始错误，并通过 Kind 支持 errors.Is 判断错误类型
+type SourceError struct {
+	Source string
+	Kind   error
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	msg := e.Kind.Error()
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Source == "" {
+		return msg
+	}
+	return e.Source + ": " + msg
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SourceError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// NewSourceError 构造指定类型的平台错误，err 可为空
+func NewSourceError(source string, kind, err error) error {
+	return &SourceError{Source: source, Kind: kind, Err: err}
+}
+
+// WrapSourceError 根据底层库返回的错误内容推断错误类型。
+// 已经是服务层类型的错误原样返回。
+func WrapSourceError(source string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *SourceError
+	if errors.As(err, &se) {
+		return err
+	}
+	return &SourceError{Source: source, Kind: classifyError(err), Err: err}
+}
+
+var (
+	authErrorHints      = []string{"require cookie", "cookie expired", "not login", "need login", "unauthorized", "status 401", "status code 401", "登录"}
+	paidErrorHints      = []string{"vip", "付费", "购买"}
+	rateLimitErrorHints = []string{"status 429", "status code 429", "too many requests", "rate limit", "频繁"}
+	timeoutErrorHints   = []string{"timeout", "deadline exceeded", "超时"}
+	notFoundErrorHints  = []string{"not found", "status 404", "status code 404", "no result", "不存在", "无版权"}
+)
+
+func classifyError(err error) error {
+	for _, kind := range []error{ErrNotFound, ErrAuthRequired, ErrPaidContent, ErrRateLimited, ErrTimeout, ErrCircuitOpen, ErrUpstream} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	// 只有超时能从 net.Error 直接判断；其余传输错误（证书校验、DNS、协议不支持等）按内容继续判断
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, authErrorHints):
+		return ErrAuthRequired
+	case containsAny(msg, paidErrorHints):
+		// 会员或付费内容与账号是否有效无关，不计入账号鉴权失败、熔断与重试
+		return ErrPaidContent
+	case containsAny(msg, rateLimitErrorHints):
+		return ErrRateLimited
+	case containsAny(msg, notFoundErrorHints):
+		return ErrNotFound
+	case containsAny(msg, timeoutErrorHints):
+		return ErrTimeout
+	default:
+		return ErrUpstream
+	}
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
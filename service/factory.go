@@ -1,11 +1,15 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/guohuiyuan/go-music-api/config"
 	"github.com/guohuiyuan/music-lib/bilibili"
 	"github.com/guohuiyuan/music-lib/fivesing"
 	"github.com/guohuiyuan/music-lib/jamendo"
@@ -22,12 +26,16 @@ import (
 
 const CookieFile = "cookies.json"
 
+// CookieManager 按平台管理 Cookie 账号池，每个平台可配置多个账号轮换使用
 type CookieManager struct {
-	mu      sync.RWMutex
-	cookies map[string]string
+	mu     sync.RWMutex
+	saveMu sync.Mutex // 从取快照到重命名全程持有，保证后取的快照后落盘
+	pools  map[string][]*CookieAccount
+	next   map[string]int // 轮询选择的下一个位置
+	key    []byte         // Cookie 文件加密密钥，为空时明文存储
 }
 
-var CM = &CookieManager{cookies: make(map[string]string)}
+var CM = &CookieManager{pools: make(map[string][]*CookieAccount), next: make(map[string]int)}
 
 type SearchFunc func(keyword string) ([]model.Song, error)
 type SearchPlaylistFunc func(keyword string) ([]model.Playlist, error)
@@ -37,21 +45,61 @@ type QRLoginCreateFunc func() (*model.QRLoginSession, error)
 type QRLoginCheckFunc func(string) (*model.QRLoginResult, error)
 type UserPlaylistsFunc func(page, limit int) ([]model.Playlist, error)
 
-func (m *CookieManager) Load() {
+// Load 读取 Cookie 文件。配置了加密密钥时，明文文件会在读取后立即加密重写。
+func (m *CookieManager) Load() error {
+	key, err := config.C.Cookies.Key()
+	if err != nil {
+		return err
+	}
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.key = key
 	data, err := os.ReadFile(CookieFile)
+	if err != nil {
+		m.mu.Unlock()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	plain, current, err := openCookieFile(data, key)
 	if err == nil {
-		_ = json.Unmarshal(data, &m.cookies)
+		err = m.decodePools(plain)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("load %s: %w", CookieFile, err)
+	}
+	if key != nil && !current {
+		return m.Save()
 	}
+	return nil
 }
 
+// Get 按配置的策略从账号池选取一个可用账号的 Cookie，所有账号都被隔离时返回空串
 func (m *CookieManager) Get(source string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	account := m.pick(source, time.Now())
+	if account == nil {
+		return ""
+	}
+	return account.Cookie
+}
+
+// Available 判断平台是否有未被隔离的账号，不会推进轮换
+func (m *CookieManager) Available(source string) bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.cookies[source]
+	now := time.Now()
+	for _, account := range m.pools[source] {
+		if !account.quarantined(now) {
+			return true
+		}
+	}
+	return false
 }
 
+// SetAll 设置各平台的默认账号 Cookie，值为空时移除默认账号，通过账号接口添加的其他账号不受影响
 func (m *CookieManager) SetAll(cookies map[string]string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -62,29 +110,80 @@ func (m *CookieManager) SetAll(cookies map[string]string) {
 			continue
 		}
 		if cookie == "" {
-			delete(m.cookies, source)
+			m.removeLocked(source, DefaultCookieAccount)
 			continue
 		}
-		m.cookies[source] = cookie
+		if account := m.find(source, DefaultCookieAccount); account != nil {
+			account.Cookie = cookie
+			account.resetHealth()
+			continue
+		}
+		m.pools[source] = append([]*CookieAccount{newCookieAccount(DefaultCookieAccount, "", cookie)}, m.pools[source]...)
 	}
 }
 
+// GetAll 返回各平台账号池中第一个账号的 Cookie
 func (m *CookieManager) GetAll() map[string]string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	result := make(map[string]string, len(m.cookies))
-	for source, cookie := range m.cookies {
-		result[source] = cookie
+	result := make(map[string]string, len(m.pools))
+	for source, accounts := range m.pools {
+		if len(accounts) > 0 {
+			result[source] = accounts[0].Cookie
+		}
 	}
 	return result
 }
 
+// GetAllMasked 返回脱敏后的 Cookies，供非管理员调用方查看
+func (m *CookieManager) GetAllMasked() map[string]string {
+	result := m.GetAll()
+	for source, cookie := range result {
+		result[source] = MaskCookie(cookie)
+	}
+	return result
+}
+
+// MaskCookie 保留每个 Cookie 的名称，只显示值的首尾少量字符
+func MaskCookie(cookie string) string {
+	var parts []string
+	for _, part := range strings.Split(cookie, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, found := strings.Cut(part, "=")
+		if !found {
+			parts = append(parts, maskCookieValue(name))
+			continue
+		}
+		parts = append(parts, name+"="+maskCookieValue(value))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func maskCookieValue(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return value[:2] + "****" + value[len(value)-2:]
+}
+
+// Save 以 0600 权限原子写入 Cookie 文件，配置了密钥时加密保存。保存串行执行，较旧的快照不会覆盖较新的数据
 func (m *CookieManager) Save() error {
-	data, err := json.MarshalIndent(m.GetAll(), "", "  ")
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	m.mu.RLock()
+	data, err := json.MarshalIndent(m.pools, "", "  ")
+	key := m.key
+	m.mu.RUnlock()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(CookieFile, data, 0644)
+	if data, err = sealCookieFile(data, key); err != nil {
+		return err
+	}
+	return writeFileAtomic(CookieFile, data, 0600)
 }
 
 func DetectSource(link string) string {
@@ -156,6 +255,11 @@ func GetRecommendSourceNames() []string {
 	return []string{"netease", "qq", "kugou", "kuwo"}
 }
 
+// GetMatchSourceNames 按歌名/歌手跨平台匹配同一首歌时搜索的平台，用于智能换源与歌词回退
+func GetMatchSourceNames() []string {
+	return []string{"netease", "qq", "kugou", "kuwo", "migu", "bilibili"}
+}
+
 func GetQRLoginSourceNames() []string {
 	return []string{"netease", "qq", "qq_wx", "kugou", "bilibili"}
 }
@@ -185,8 +289,11 @@ func GetSourceDescription(source string) string {
 	return "未知音乐源"
 }
 
-func GetSearchFunc(source string) SearchFunc {
-	c := CM.Get(source)
+func GetSearchFunc(ctx context.Context, source string) SearchFunc {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapSearch}, searchFunc)
+}
+
+func searchFunc(source, c string) SearchFunc {
 	switch source {
 	case "netease":
 		return netease.New(c).Search
@@ -215,8 +322,11 @@ func GetSearchFunc(source string) SearchFunc {
 	}
 }
 
-func GetAlbumSearchFunc(source string) SearchPlaylistFunc {
-	c := CM.Get(source)
+func GetAlbumSearchFunc(ctx context.Context, source string) SearchPlaylistFunc {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapAlbumSearch}, albumSearchFunc)
+}
+
+func albumSearchFunc(source, c string) SearchPlaylistFunc {
 	switch source {
 	case "netease":
 		return netease.New(c).SearchAlbum
@@ -241,8 +351,11 @@ func GetAlbumSearchFunc(source string) SearchPlaylistFunc {
 	}
 }
 
-func GetDownloadFunc(source string) func(*model.Song) (string, error) {
-	c := CM.Get(source)
+func GetDownloadFunc(ctx context.Context, source string) func(*model.Song) (string, error) {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapDownloadURL}, downloadFunc)
+}
+
+func downloadFunc(source, c string) func(*model.Song) (string, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).GetDownloadURL
@@ -271,8 +384,11 @@ func GetDownloadFunc(source string) func(*model.Song) (string, error) {
 	}
 }
 
-func GetLyricFunc(source string) func(*model.Song) (string, error) {
-	c := CM.Get(source)
+func GetLyricFunc(ctx context.Context, source string) func(*model.Song) (string, error) {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapLyric}, lyricFunc)
+}
+
+func lyricFunc(source, c string) func(*model.Song) (string, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).GetLyrics
@@ -301,8 +417,11 @@ func GetLyricFunc(source string) func(*model.Song) (string, error) {
 	}
 }
 
-func GetParseFunc(source string) func(string) (*model.Song, error) {
-	c := CM.Get(source)
+func GetParseFunc(ctx context.Context, source string) func(string) (*model.Song, error) {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapParse}, parseFunc)
+}
+
+func parseFunc(source, c string) func(string) (*model.Song, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).Parse
@@ -333,8 +452,11 @@ func GetParseFunc(source string) func(string) (*model.Song, error) {
 
 // --- 追加：歌单相关工厂函数 ---
 
-func GetPlaylistSearchFunc(source string) SearchPlaylistFunc {
-	c := CM.Get(source)
+func GetPlaylistSearchFunc(ctx context.Context, source string) SearchPlaylistFunc {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapPlaylistSearch}, playlistSearchFunc)
+}
+
+func playlistSearchFunc(source, c string) SearchPlaylistFunc {
 	switch source {
 	case "netease":
 		return netease.New(c).SearchPlaylist
@@ -363,8 +485,11 @@ func GetPlaylistSearchFunc(source string) SearchPlaylistFunc {
 	}
 }
 
-func GetAlbumDetailFunc(source string) func(string) ([]model.Song, error) {
-	c := CM.Get(source)
+func GetAlbumDetailFunc(ctx context.Context, source string) func(string) ([]model.Song, error) {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapAlbumDetail}, albumDetailFunc)
+}
+
+func albumDetailFunc(source, c string) func(string) ([]model.Song, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).GetAlbumSongs
@@ -389,8 +514,11 @@ func GetAlbumDetailFunc(source string) func(string) ([]model.Song, error) {
 	}
 }
 
-func GetPlaylistDetailFunc(source string) func(string) ([]model.Song, error) {
-	c := CM.Get(source)
+func GetPlaylistDetailFunc(ctx context.Context, source string) func(string) ([]model.Song, error) {
+	return pooled1(upstreamCall{ctx: ctx, source: source, capability: CapPlaylistDetail}, playlistDetailFunc)
+}
+
+func playlistDetailFunc(source, c string) func(string) ([]model.Song, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).GetPlaylistSongs
@@ -419,8 +547,11 @@ func GetPlaylistDetailFunc(source string) func(string) ([]model.Song, error) {
 	}
 }
 
-func GetRecommendFunc(source string) func() ([]model.Playlist, error) {
-	c := CM.Get(source)
+func GetRecommendFunc(ctx context.Context, source string) func() ([]model.Playlist, error) {
+	return pooled0(upstreamCall{ctx: ctx, source: source, capability: CapRecommend}, recommendFunc)
+}
+
+func recommendFunc(source, c string) func() ([]model.Playlist, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).GetRecommendedPlaylists
@@ -435,8 +566,11 @@ func GetRecommendFunc(source string) func() ([]model.Playlist, error) {
 	}
 }
 
-func GetPlaylistCategoriesFunc(source string) PlaylistCategoriesFunc {
-	c := CM.Get(source)
+func GetPlaylistCategoriesFunc(ctx context.Context, source string) PlaylistCategoriesFunc {
+	return pooled0(upstreamCall{ctx: ctx, source: source, capability: CapPlaylistCategories}, playlistCategoriesFunc)
+}
+
+func playlistCategoriesFunc(source, c string) PlaylistCategoriesFunc {
 	switch source {
 	case "netease":
 		return netease.New(c).GetPlaylistCategories
@@ -457,8 +591,11 @@ func GetPlaylistCategoriesFunc(source string) PlaylistCategoriesFunc {
 	}
 }
 
-func GetCategoryPlaylistsFunc(source string) CategoryPlaylistsFunc {
-	c := CM.Get(source)
+func GetCategoryPlaylistsFunc(ctx context.Context, source string) CategoryPlaylistsFunc {
+	return pooled3(upstreamCall{ctx: ctx, source: source, capability: CapCategoryPlaylists}, categoryPlaylistsFunc)
+}
+
+func categoryPlaylistsFunc(source, c string) CategoryPlaylistsFunc {
 	switch source {
 	case "netease":
 		return netease.New(c).GetCategoryPlaylists
@@ -479,7 +616,11 @@ func GetCategoryPlaylistsFunc(source string) CategoryPlaylistsFunc {
 	}
 }
 
-func GetQRLoginCreateFunc(source string) QRLoginCreateFunc {
+func GetQRLoginCreateFunc(ctx context.Context, source string) QRLoginCreateFunc {
+	return wrap0(upstreamCall{ctx: ctx, source: source, capability: CapQRLoginCreate}, qrLoginCreateFunc(source))
+}
+
+func qrLoginCreateFunc(source string) QRLoginCreateFunc {
 	switch source {
 	case "netease":
 		return netease.CreateQRLogin
@@ -496,7 +637,11 @@ func GetQRLoginCreateFunc(source string) QRLoginCreateFunc {
 	}
 }
 
-func GetQRLoginCheckFunc(source string) QRLoginCheckFunc {
+func GetQRLoginCheckFunc(ctx context.Context, source string) QRLoginCheckFunc {
+	return wrap1(upstreamCall{ctx: ctx, source: source, capability: CapQRLoginCheck}, qrLoginCheckFunc(source))
+}
+
+func qrLoginCheckFunc(source string) QRLoginCheckFunc {
 	switch source {
 	case "netease":
 		return netease.CheckQRLogin
@@ -513,8 +658,11 @@ func GetQRLoginCheckFunc(source string) QRLoginCheckFunc {
 	}
 }
 
-func GetUserPlaylistsFunc(source string) UserPlaylistsFunc {
-	c := CM.Get(source)
+func GetUserPlaylistsFunc(ctx context.Context, source string) UserPlaylistsFunc {
+	return pooled2(upstreamCall{ctx: ctx, source: source, capability: CapUserPlaylists}, userPlaylistsFunc)
+}
+
+func userPlaylistsFunc(source, c string) UserPlaylistsFunc {
 	switch source {
 	case "netease":
 		return netease.New(c).GetUserPlaylists
@@ -527,8 +675,11 @@ func GetUserPlaylistsFunc(source string) UserPlaylistsFunc {
 	}
 }
 
-func GetParsePlaylistFunc(source string) func(string) (*model.Playlist, []model.Song, error) {
-	c := CM.Get(source)
+func GetParsePlaylistFunc(ctx context.Context, source string) func(string) (*model.Playlist, []model.Song, error) {
+	return pooled1x2(upstreamCall{ctx: ctx, source: source, capability: CapParsePlaylist}, parsePlaylistFunc)
+}
+
+func parsePlaylistFunc(source, c string) func(string) (*model.Playlist, []model.Song, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).ParsePlaylist
@@ -557,8 +708,11 @@ func GetParsePlaylistFunc(source string) func(string) (*model.Playlist, []model.
 	}
 }
 
-func GetParseAlbumFunc(source string) func(string) (*model.Playlist, []model.Song, error) {
-	c := CM.Get(source)
+func GetParseAlbumFunc(ctx context.Context, source string) func(string) (*model.Playlist, []model.Song, error) {
+	return pooled1x2(upstreamCall{ctx: ctx, source: source, capability: CapParseAlbum}, parseAlbumFunc)
+}
+
+func parseAlbumFunc(source, c string) func(string) (*model.Playlist, []model.Song, error) {
 	switch source {
 	case "netease":
 		return netease.New(c).ParseAlbum
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// httpClients 按出站代理缓存共享客户端，同一代理下的所有平台复用一个连接池
+var httpClients = struct {
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}{clients: make(map[string]*http.Client)}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// HTTPClient 返回访问平台音频直链使用的共享客户端。客户端不设整体超时，
+// 串流时长由请求的 context 与 http_client.stream_idle_seconds 控制
+func HTTPClient(source string) *http.Client {
+	proxy := config.C.Proxy.For(source)
+	httpClients.mu.Lock()
+	defer httpClients.mu.Unlock()
+	if client := httpClients.clients[proxy]; client != nil {
+		return client
+	}
+	client := &http.Client{Transport: newTransport(proxy)}
+	httpClients.clients[proxy] = client
+	return client
+}
+
+// newTransport 创建带连接池、各阶段超时并启用 HTTP/2 的 Transport，proxy 为空时直连。
+// 代理地址在加载配置时已校验
+func newTransport(proxy string) *http.Transport {
+	cfg := config.C.HTTP
+	dialer := &net.Dialer{Timeout: seconds(cfg.DialTimeoutSeconds), KeepAlive: 30 * time.Second}
+	t := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       seconds(cfg.IdleConnTimeoutSeconds),
+		TLSHandshakeTimeout:   seconds(cfg.TLSHandshakeTimeoutSeconds),
+		ResponseHeaderTimeout: seconds(cfg.ResponseHeaderTimeoutSeconds),
+		ExpectContinueTimeout: time.Second,
+	}
+	if proxy != "" {
+		if u, err := url.Parse(proxy); err == nil {
+			t.Proxy = http.ProxyURL(u)
+		}
+	}
+	return t
+}
+
+// Stream 以共享客户端发起串流请求。等待上游数据超过 stream_idle_seconds 时取消请求，
+// 避免上游卡住时连接一直挂起；客户端暂停读取的时间不计入
+func Stream(source string, req *http.Request) (*http.Response, error) {
+	idle := seconds(config.C.HTTP.StreamIdleSeconds)
+	if idle <= 0 {
+		return HTTPClient(source).Do(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := HTTPClient(source).Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	timer := time.AfterFunc(idle, cancel)
+	timer.Stop()
+	resp.Body = &idleBody{ReadCloser: resp.Body, idle: idle, timer: timer, cancel: cancel}
+	return resp, nil
+}
+
+// idleBody 只在阻塞读取上游时计时，超时后取消请求使 Read 返回错误
+type idleBody struct {
+	io.ReadCloser
+	idle   time.Duration
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.idle)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// cancelBody 在关闭响应体时释放请求的超时 context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/guohuiyuan/go-music-api/metrics"
+)
+
+// LRU 容量固定的最近最少使用缓存，并发安全；查询结果按名称上报到 music_api_cache_requests_total
+type LRU[V any] struct {
+	name  string
+	size  int
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List // 表头为最近使用
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+// NewLRU 创建最多保存 size 项的缓存
+func NewLRU[V any](name string, size int) *LRU[V] {
+	return &LRU[V]{name: name, size: max(size, 1), items: make(map[string]*list.Element), order: list.New()}
+}
+
+// Get 返回缓存的值并将其标记为最近使用
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		metrics.CacheRequests.WithLabelValues(c.name, metrics.CacheMiss).Inc()
+		var zero V
+		return zero, false
+	}
+	metrics.CacheRequests.WithLabelValues(c.name, metrics.CacheHit).Inc()
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry[V]).value, true
+}
+
+// Peek 返回缓存的值，不改变淘汰顺序，也不计入命中统计
+func (c *LRU[V]) Peek(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Add 写入或覆盖缓存项，超出容量时淘汰最久未使用的一项
+func (c *LRU[V]) Add(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruEntry[V]).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// Len 返回当前缓存项数
+func (c *LRU[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
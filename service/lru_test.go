@@ -0,0 +1,23 @@
+package service
+
+import "testing"
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[int]("test", 2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v", v, ok)
+	}
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected a to survive, it was used more recently than b")
+	}
+	c.Add("c", 4)
+	if v, _ := c.Get("c"); v != 4 || c.Len() != 2 {
+		t.Errorf("Get(c) = %d, Len = %d", v, c.Len())
+	}
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 歌词导出格式
+const (
+	LyricFormatLRC  = "lrc"
+	LyricFormatSRT  = "srt"
+	LyricFormatVTT  = "vtt"
+	LyricFormatASS  = "ass"
+	LyricFormatTTML = "ttml"
+)
+
+// 最后一行歌词在缺少歌曲时长时的默认显示时间
+const lastLyricLineDuration = 5 * time.Second
+
+var (
+	lrcTimeTagRe = regexp.MustCompile(`^\[(\d{1,3}):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	lrcMetaTagRe = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+	lrcWordTagRe = regexp.MustCompile(`<(\d{1,3}):(\d{1,2})[.:](\d{1,3})>`)
+)
+
+// LyricWord 逐字歌词中的一个片段
+type LyricWord struct {
+	Start time.Duration
+	Text  string
+}
+
+// LyricLine 解析后的一行带时间轴的歌词
+type LyricLine struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+	Words []LyricWord
+}
+
+type lyricFormatInfo struct {
+	ext  string
+	mime string
+}
+
+var lyricFormats = map[string]lyricFormatInfo{
+	LyricFormatLRC:  {ext: "lrc", mime: "text/plain; charset=utf-8"},
+	LyricFormatSRT:  {ext: "srt", mime: "application/x-subrip; charset=utf-8"},
+	LyricFormatVTT:  {ext: "vtt", mime: "text/vtt; charset=utf-8"},
+	LyricFormatASS:  {ext: "ass", mime: "text/x-ssa; charset=utf-8"},
+	LyricFormatTTML: {ext: "ttml", mime: "application/ttml+xml; charset=utf-8"},
+}
+
+// GetLyricFormatInfo 返回导出格式对应的文件后缀与 MIME 类型
+func GetLyricFormatInfo(format string) (ext, mime string, ok bool) {
+	info, ok := lyricFormats[format]
+	return info.ext, info.mime, ok
+}
+
+func parseLrcTimestamp(m []string) time.Duration {
+	minutes, _ := strconv.Atoi(m[1])
+	seconds, _ := strconv.Atoi(m[2])
+	millis := 0
+	if frac := m[3]; frac != "" {
+		for len(frac) < 3 {
+			frac += "0"
+		}
+		millis, _ = strconv.Atoi(frac)
+	}
+	return time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second + time.Duration(millis)*time.Millisecond
+}
+
+func parseLrcWords(text string, lineStart time.Duration) (string, []LyricWord) {
+	locs := lrcWordTagRe.FindAllStringSubmatchIndex(text, -1)
+	if len(locs) == 0 {
+		return strings.TrimSpace(text), nil
+	}
+	var words []LyricWord
+	var plain strings.Builder
+	if head := text[:locs[0][0]]; strings.TrimSpace(head) != "" {
+		words = append(words, LyricWord{Start: lineStart, Text: head})
+		plain.WriteString(head)
+	}
+	for i, loc := range locs {
+		start := parseLrcTimestamp([]string{"", text[loc[2]:loc[3]], text[loc[4]:loc[5]], text[loc[6]:loc[7]]})
+		end := len(text)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		segment := text[loc[1]:end]
+		plain.WriteString(segment)
+		if segment != "" {
+			words = append(words, LyricWord{Start: start, Text: segment})
+		}
+	}
+	return strings.TrimSpace(plain.String()), words
+}
+
+// ParseLRC 将 LRC 文本解析为按时间排序的歌词行。
+// 支持单行多时间标签、[offset:] 标签以及 <mm:ss.xx> 形式的逐字时间。
+// duration 为歌曲时长(秒)，用于推算最后一行的结束时间。
+func ParseLRC(lrc string, duration int) []LyricLine {
+	var lines []LyricLine
+	var offset time.Duration
+	for _, raw := range strings.Split(strings.ReplaceAll(lrc, "\r\n", "\n"), "\n") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		var starts []time.Duration
+		rest := raw
+		for {
+			m := lrcTimeTagRe.FindStringSubmatch(rest)
+			if m == nil {
+				break
+			}
+			starts = append(starts, parseLrcTimestamp(m))
+			rest = rest[len(m[0]):]
+		}
+		if len(starts) == 0 {
+			if m := lrcMetaTagRe.FindStringSubmatch(raw); m != nil && strings.EqualFold(m[1], "offset") {
+				if ms, err := strconv.Atoi(strings.TrimSpace(m[2])); err == nil {
+					offset = time.Duration(ms) * time.Millisecond
+				}
+			}
+			continue
+		}
+		for _, start := range starts {
+			text, words := parseLrcWords(rest, start)
+			lines = append(lines, LyricLine{Start: start, Text: text, Words: words})
+		}
+	}
+
+	// LRC 规范中正的 offset 表示歌词整体提前
+	if offset != 0 {
+		for i := range lines {
+			lines[i].Start = clampLyricTime(lines[i].Start - offset)
+			for j := range lines[i].Words {
+				lines[i].Words[j].Start = clampLyricTime(lines[i].Words[j].Start - offset)
+			}
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Start < lines[j].Start })
+
+	songEnd := time.Duration(duration) * time.Second
+	for i := range lines {
+		end := time.Duration(0)
+		// 双语歌词会出现相同时间戳的行，结束时间取下一个不同的时间戳
+		for j := i + 1; j < len(lines); j++ {
+			if lines[j].Start > lines[i].Start {
+				end = lines[j].Start
+				break
+			}
+		}
+		if end == 0 {
+			if songEnd > lines[i].Start {
+				end = songEnd
+			} else {
+				end = lines[i].Start + lastLyricLineDuration
+			}
+		}
+		lines[i].End = end
+	}
+	return lines
+}
+
+func clampLyricTime(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// ConvertLyric 将 LRC 歌词转换为指定格式的字幕文本
+func ConvertLyric(lrc, format string, duration int) (string, error) {
+	if format == LyricFormatLRC {
+		return lrc, nil
+	}
+	if _, ok := lyricFormats[format]; !ok {
+		return "", fmt.Errorf("unsupported lyric format: %s", format)
+	}
+	lines := ParseLRC(lrc, duration)
+	visible := make([]LyricLine, 0, len(lines))
+	for _, line := range lines {
+		// 空行仅作为上一行的结束标记
+		if line.Text != "" {
+			visible = append(visible, line)
+		}
+	}
+	switch format {
+	case LyricFormatSRT:
+		return renderSRT(visible), nil
+	case LyricFormatVTT:
+		return renderVTT(visible), nil
+	case LyricFormatASS:
+		return renderASS(visible), nil
+	default:
+		return renderTTML(visible), nil
+	}
+}
+
+func splitDuration(d time.Duration) (h, m, s, ms int) {
+	total := int(d / time.Millisecond)
+	return total / 3600000, total / 60000 % 60, total / 1000 % 60, total % 1000
+}
+
+func formatSRTTime(d time.Duration) string {
+	h, m, s, ms := splitDuration(d)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
+
+func formatVTTTime(d time.Duration) string {
+	h, m, s, ms := splitDuration(d)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+func formatASSTime(d time.Duration) string {
+	h, m, s, ms := splitDuration(d)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, ms/10)
+}
+
+func renderSRT(lines []LyricLine) string {
+	var b strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatSRTTime(line.Start), formatSRTTime(line.End), line.Text)
+	}
+	return b.String()
+}
+
+func renderVTT(lines []LyricLine) string {
+	escaper := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, line := range lines {
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatVTTTime(line.Start), formatVTTTime(line.End), escaper.Replace(line.Text))
+	}
+	return b.String()
+}
+
+const assHeader = `[Script Info]
+ScriptType: v4.00+
+WrapStyle: 0
+PlayResX: 1280
+PlayResY: 720
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,48,&H00FFFFFF,&H0000FFFF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,1,2,20,20,40,1
+
+[Events]
+Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+`
+
+// assEscaper 转义 ASS 文本中的特殊字符：花括号会开启样式覆盖块，
+// 反斜杠后插入不可见的 U+2060，使 \N、\h 等序列按原文显示
+var assEscaper = strings.NewReplacer("{", `\{`, "}", `\}`, `\`, "\\\u2060")
+
+func renderASS(lines []LyricLine) string {
+	var b strings.Builder
+	b.WriteString(assHeader)
+	for _, line := range lines {
+		text := assEscaper.Replace(line.Text)
+		if len(line.Words) > 0 {
+			// 逐字歌词生成 \k 卡拉 OK 标签，单位为厘秒
+			var k strings.Builder
+			for i, word := range line.Words {
+				next := line.End
+				if i+1 < len(line.Words) {
+					next = line.Words[i+1].Start
+				}
+				cs := int((next - word.Start) / (10 * time.Millisecond))
+				if cs < 0 {
+					cs = 0
+				}
+				fmt.Fprintf(&k, "{\\k%d}%s", cs, assEscaper.Replace(word.Text))
+			}
+			text = k.String()
+		}
+		fmt.Fprintf(&b, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", formatASSTime(line.Start), formatASSTime(line.End), text)
+	}
+	return b.String()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func renderTTML(lines []LyricLine) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:timeBase="media">` + "\n")
+	b.WriteString("  <body>\n    <div>\n")
+	for _, line := range lines {
+		fmt.Fprintf(&b, `      <p begin="%s" end="%s">`, formatVTTTime(line.Start), formatVTTTime(line.End))
+		if len(line.Words) > 0 {
+			for i, word := range line.Words {
+				next := line.End
+				if i+1 < len(line.Words) {
+					next = line.Words[i+1].Start
+				}
+				fmt.Fprintf(&b, `<span begin="%s" end="%s">%s</span>`, formatVTTTime(word.Start), formatVTTTime(next), xmlEscape(word.Text))
+			}
+		} else {
+			b.WriteString(xmlEscape(line.Text))
+		}
+		b.WriteString("</p>\n")
+	}
+	b.WriteString("    </div>\n  </body>\n</tt>\n")
+	return b.String()
+}
+
+var lrcOffsetTagRe = regexp.MustCompile(`(?mi)^[ \t]*\[offset:[ \t]*([+-]?\d+)[ \t]*\][ \t]*$`)
+
+// ShiftLyric 将 LRC 中的所有行时间与逐字时间整体平移 offsetMs 毫秒 (正数表示歌词延后显示)。
+// 只改写 ParseLRC 识别的行首时间标签和逐字时间标签，歌词正文中形似时间的文本保持不变。
+// 原有的 [offset:] 标签会一并折算进时间戳，并改写为 [offset:0]。
+func ShiftLyric(lrc string, offsetMs int) string {
+	tagOffset := 0
+	if m := lrcOffsetTagRe.FindStringSubmatch(lrc); m != nil {
+		tagOffset, _ = strconv.Atoi(m[1])
+	}
+	shift := time.Duration(offsetMs-tagOffset) * time.Millisecond
+	if shift == 0 && tagOffset == 0 {
+		return lrc
+	}
+
+	shiftTag := func(m []string, open, close string) string {
+		t := clampLyricTime(parseLrcTimestamp(m) + shift)
+		return open + formatLrcTime(t, len(m[3])) + close
+	}
+	lines := strings.Split(lrc, "\n")
+	for i, line := range lines {
+		rest := strings.TrimLeft(line, " \t")
+		var b strings.Builder
+		b.WriteString(line[:len(line)-len(rest)])
+		timed := false
+		for {
+			m := lrcTimeTagRe.FindStringSubmatch(rest)
+			if m == nil {
+				break
+			}
+			timed = true
+			b.WriteString(shiftTag(m, "[", "]"))
+			rest = rest[len(m[0]):]
+		}
+		if !timed {
+			continue
+		}
+		b.WriteString(lrcWordTagRe.ReplaceAllStringFunc(rest, func(tag string) string {
+			return shiftTag(lrcWordTagRe.FindStringSubmatch(tag), "<", ">")
+		}))
+		lines[i] = b.String()
+	}
+	return lrcOffsetTagRe.ReplaceAllString(strings.Join(lines, "\n"), "[offset:0]")
+}
+
+// formatLrcTime 按原时间戳的小数位数输出 mm:ss.xx 或 mm:ss.xxx
+func formatLrcTime(d time.Duration, fracDigits int) string {
+	total := int(d / time.Millisecond)
+	minutes, seconds, millis := total/60000, total/1000%60, total%1000
+	if fracDigits == 3 {
+		return fmt.Sprintf("%02d:%02d.%03d", minutes, seconds, millis)
+	}
+	return fmt.Sprintf("%02d:%02d.%02d", minutes, seconds, millis/10)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const LyricOffsetFile = "lyric_offsets.json"
+
+// MaxLyricOffsetMs 允许设置的歌词偏移上限 (毫秒)
+const MaxLyricOffsetMs = 10 * 60 * 1000
+
+// MaxLyricOffsetIDLen 歌曲 ID 的最大长度，避免校正文件被超长键撑大
+const MaxLyricOffsetIDLen = 128
+
+// LyricOffsetManager 按歌曲持久化歌词时间偏移校正，键为 source:id
+type LyricOffsetManager struct {
+	mu      sync.RWMutex
+	saveMu  sync.Mutex // 串行化保存，保证后取的快照后落盘
+	offsets map[string]int
+}
+
+var LOM = &LyricOffsetManager{offsets: make(map[string]int)}
+
+func lyricOffsetKey(source, id string) string {
+	return strings.TrimSpace(source) + ":" + strings.TrimSpace(id)
+}
+
+// Load 读取校正文件，文件不存在时保持为空；文件损坏时返回错误且不改动已有的校正
+func (m *LyricOffsetManager) Load() error {
+	if err := m.load(LyricOffsetFile); err != nil {
+		return fmt.Errorf("load %s: %w", LyricOffsetFile, err)
+	}
+	return nil
+}
+
+func (m *LyricOffsetManager) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var offsets map[string]int
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return err
+	}
+	// 文件内容为 null 时解析结果为 nil，之后的 Set 写入会 panic
+	if offsets == nil {
+		offsets = make(map[string]int)
+	}
+	m.mu.Lock()
+	m.offsets = offsets
+	m.mu.Unlock()
+	return nil
+}
+
+// Get 返回歌曲已保存的偏移，ok 表示是否存在校正记录
+func (m *LyricOffsetManager) Get(source, id string) (offsetMs int, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	offsetMs, ok = m.offsets[lyricOffsetKey(source, id)]
+	return offsetMs, ok
+}
+
+// Set 保存歌曲的偏移，offsetMs 为 0 时删除记录
+func (m *LyricOffsetManager) Set(source, id string, offsetMs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := lyricOffsetKey(source, id)
+	if offsetMs == 0 {
+		delete(m.offsets, key)
+		return
+	}
+	m.offsets[key] = offsetMs
+}
+
+// Save 以 0600 权限原子写入校正文件
+func (m *LyricOffsetManager) Save() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	m.mu.RLock()
+	data, err := json.MarshalIndent(m.offsets, "", "  ")
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(LyricOffsetFile, data, 0600)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLyricOffsetLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	m := &LyricOffsetManager{offsets: make(map[string]int)}
+	if err := m.load(write("valid.json", `{"qq:1": 500}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := m.Get("qq", "1"); !ok || got != 500 {
+		t.Fatalf("Get = %d, %v; want 500, true", got, ok)
+	}
+
+	// 损坏的文件返回错误，已加载的校正保持不变
+	if err := m.load(write("corrupt.json", `{"qq:1": `)); err == nil {
+		t.Fatal("load accepted corrupt json")
+	}
+	if got, ok := m.Get("qq", "1"); !ok || got != 500 {
+		t.Fatalf("corrupt file changed offsets: %d, %v", got, ok)
+	}
+
+	if err := m.load(write("null.json", "null")); err != nil {
+		t.Fatal(err)
+	}
+	m.Set("qq", "2", 300) // 解析结果为 nil 时写入会 panic
+	if got, _ := m.Get("qq", "2"); got != 300 {
+		t.Fatalf("Get after null file = %d, want 300", got)
+	}
+
+	if err := m.load(filepath.Join(dir, "missing.json")); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+const testLRC = `[ti:Test]
+[00:01.00]Hello <World>
+[00:03.50]
+[00:04.00]Fish & Chips
+`
+
+func TestConvertLyricSRT(t *testing.T) {
+	got, err := ConvertLyric(testLRC, LyricFormatSRT, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:01,000 --> 00:00:03,500\nHello <World>\n\n" +
+		"2\n00:00:04,000 --> 00:00:06,000\nFish & Chips\n\n"
+	if got != want {
+		t.Errorf("srt mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestConvertLyricVTT(t *testing.T) {
+	got, err := ConvertLyric(testLRC, LyricFormatVTT, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n" +
+		"00:00:01.000 --> 00:00:03.500\nHello &lt;World&gt;\n\n" +
+		"00:00:04.000 --> 00:00:06.000\nFish &amp; Chips\n\n"
+	if got != want {
+		t.Errorf("vtt mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestConvertLyricASS(t *testing.T) {
+	lrc := "[00:01.00]{\\b1}bold\\Nline\n[00:02.00]<00:02.00>La<00:02.50>La\n"
+	got, err := ConvertLyric(lrc, LyricFormatASS, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "[Script Info]\n") {
+		t.Fatalf("missing ass header: %q", got)
+	}
+	_, events, _ := strings.Cut(got, "Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+	want := "Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,\\{\\\u2060b1\\}bold\\\u2060Nline\n" +
+		"Dialogue: 0,0:00:02.00,0:00:03.00,Default,,0,0,0,,{\\k50}La{\\k50}La\n"
+	if events != want {
+		t.Errorf("ass events mismatch:\n got %q\nwant %q", events, want)
+	}
+}
+
+func TestConvertLyricTTML(t *testing.T) {
+	lrc := "[00:01.00]Fish & Chips\n[00:02.00]<00:02.00>La<00:02.50>La\n"
+	got, err := ConvertLyric(lrc, LyricFormatTTML, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:timeBase="media">` + "\n" +
+		"  <body>\n    <div>\n" +
+		`      <p begin="00:00:01.000" end="00:00:02.000">Fish &amp; Chips</p>` + "\n" +
+		`      <p begin="00:00:02.000" end="00:00:03.000"><span begin="00:00:02.000" end="00:00:02.500">La</span><span begin="00:00:02.500" end="00:00:03.000">La</span></p>` + "\n" +
+		"    </div>\n  </body>\n</tt>\n"
+	if got != want {
+		t.Errorf("ttml mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestConvertLyricOffsetTag(t *testing.T) {
+	got, err := ConvertLyric("[offset:500]\n[00:01.00]A\n", LyricFormatSRT, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1\n00:00:00,500 --> 00:00:02,000\nA\n\n"; got != want {
+		t.Errorf("offset mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestConvertLyricUnsupported(t *testing.T) {
+	if _, err := ConvertLyric(testLRC, "docx", 0); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestShiftLyric(t *testing.T) {
+	lrc := "[ti:Test]\n[00:01.00][00:05.500]Hello\n[00:02.00]<00:02.00>La<00:02.50>La\n"
+	want := "[ti:Test]\n[00:01.25][00:05.750]Hello\n[00:02.25]<00:02.25>La<00:02.75>La\n"
+	if got := ShiftLyric(lrc, 250); got != want {
+		t.Errorf("shift mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestShiftLyricKeepsTimeLikeText(t *testing.T) {
+	lrc := "[00:01.00]Meet me at <03:15> or [04:20]\nPlain <00:09.00> note\n"
+	want := "[00:00.50]Meet me at <03:15> or [04:20]\nPlain <00:09.00> note\n"
+	if got := ShiftLyric(lrc, -500); got != want {
+		t.Errorf("shift mismatch:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestShiftLyricFoldsOffsetTag(t *testing.T) {
+	lrc := "[offset:500]\n[00:01.00]A\n"
+	want := "[offset:0]\n[00:00.50]A\n"
+	if got := ShiftLyric(lrc, 0); got != want {
+		t.Errorf("shift mismatch:\n got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/music-lib/model"
+)
+
+// QRStateExpired 会话超过有效期，不再接受轮询
+const QRStateExpired = "expired"
+
+// ErrQRSessionExpired 扫码登录会话已过期
+var ErrQRSessionExpired = errors.New("qr login session expired")
+
+// qrSessionRetention 过期会话在会话表中保留的时长，便于管理员排查
+const qrSessionRetention = 10 * time.Minute
+
+// QRSession 服务端记录的扫码登录会话
+type QRSession struct {
+	Source    string    `json:"source"`
+	Key       string    `json:"key"`
+	State     string    `json:"state"`
+	CreatedBy string    `json:"created_by,omitempty"` // 创建者的 API Key 名称或 user:<用户名>
+	User      string    `json:"user,omitempty"`       // 以用户令牌创建时的用户名，登录成功后 Cookie 存入其保险箱
+	Admin     bool      `json:"admin"`                // 创建者为管理员，登录成功后 Cookie 存入全局账号池
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// CookieSaved 登录成功得到的 Cookie 是否已保存，CookieError 为最近一次保存失败的原因
+	CookieSaved bool   `json:"cookie_saved"`
+	CookieError string `json:"cookie_error,omitempty"`
+
+	session *model.QRLoginSession
+	cookie  string // 登录成功但保存失败的 Cookie，后续轮询时重新保存
+}
+
+// Terminal 会话是否已结束，结束后不再需要轮询；登录成功但 Cookie 尚未保存时仍需轮询以重新保存
+func (s *QRSession) Terminal() bool {
+	if s.cookie != "" {
+		return false
+	}
+	switch s.State {
+	case string(model.QRLoginStatusSuccess), QRStateExpired, "failed", "canceled", "cancelled":
+		return true
+	}
+	return false
+}
+
+// LoginSession 返回创建会话时平台返回的原始会话信息
+func (s *QRSession) LoginSession() *model.QRLoginSession {
+	return s.session
+}
+
+// QRSessionManager 扫码登录会话表，会话超过 qr_login.session_ttl_seconds 后自动过期
+type QRSessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*QRSession
+	watches  map[string]*qrWatch
+}
+
+var QRM = &QRSessionManager{sessions: make(map[string]*QRSession), watches: make(map[string]*qrWatch)}
+
+func qrSessionKey(source, key string) string {
+	return source + "\x00" + key
+}
+
+// Create 记录新创建的扫码登录会话
+func (m *QRSessionManager) Create(source string, session *model.QRLoginSession, createdBy, user string, admin bool) (QRSession, error) {
+	if session == nil || session.Key == "" {
+		return QRSession{}, NewSourceError(source, ErrUpstream, errors.New("qr login session has no key"))
+	}
+	now := time.Now()
+	s := &QRSession{
+		Source:    source,
+		Key:       session.Key,
+		State:     string(model.QRLoginStatusWaiting),
+		CreatedBy: createdBy,
+		User:      user,
+		Admin:     admin,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(time.Duration(config.C.QRLogin.SessionTTLSeconds) * time.Second),
+		session:   session,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now)
+	m.sessions[qrSessionKey(source, session.Key)] = s
+	return *s, nil
+}
+
+// Get 返回仍在有效期内的会话，未知的 key 返回 ErrNotFound，已过期返回 ErrQRSessionExpired
+func (m *QRSessionManager) Get(source, key string) (QRSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[qrSessionKey(source, key)]
+	if s == nil {
+		return QRSession{}, NewSourceError(source, ErrNotFound, errors.New("unknown qr login key"))
+	}
+	m.expire(s, time.Now())
+	if s.State == QRStateExpired {
+		return *s, NewSourceError(source, ErrQRSessionExpired, nil)
+	}
+	return *s, nil
+}
+
+// Update 记录会话的最新状态，返回本次调用是否让会话首次进入登录成功状态。
+// 判断与写入在同一把锁内完成，并发轮询时只有一个调用方会保存 Cookie
+func (m *QRSessionManager) Update(source, key, state string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[qrSessionKey(source, key)]
+	if s == nil || state == "" {
+		return false
+	}
+	first := state == string(model.QRLoginStatusSuccess) && s.State != state
+	s.State = state
+	s.UpdatedAt = time.Now()
+	return first
+}
+
+// List 返回会话表中的全部会话，按创建时间倒序
+func (m *QRSessionManager) List() []QRSession {
+	now := time.Now()
+	m.mu.Lock()
+	m.prune(now)
+	result := make([]QRSession, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		m.expire(s, now)
+		result = append(result, *s)
+	}
+	m.mu.Unlock()
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return result
+}
+
+// Check 向平台查询一次登录状态并更新会话；首次登录成功时按会话创建者保存 Cookie，并在 Extra 中注明保存位置。
+// 保存失败时 Extra 中 cookie_saved 为 false 并附带原因，Cookie 留在会话中，之后的轮询不再访问平台而是重新保存
+func (m *QRSessionManager) Check(ctx context.Context, source, key string) (*model.QRLoginResult, error) {
+	session, err := m.Get(source, key)
+	if err != nil {
+		return nil, err
+	}
+	if session.cookie != "" {
+		result := &model.QRLoginResult{Status: model.QRLoginStatusSuccess}
+		m.persistCookie(session, result, session.cookie)
+		return result, nil
+	}
+	fn := GetQRLoginCheckFunc(ctx, source)
+	if fn == nil {
+		return nil, NewSourceError(source, ErrNotFound, errors.New("unsupported qr login source"))
+	}
+	result, err := fn(key)
+	if err != nil || result == nil {
+		return result, err
+	}
+	if !m.Update(source, key, string(result.Status)) {
+		return result, nil
+	}
+	cookie := QRLoginCookie(result)
+	if cookie == "" {
+		return result, nil
+	}
+	m.persistCookie(session, result, cookie)
+	return result, nil
+}
+
+// persistCookie 保存登录成功得到的 Cookie，在会话与 result.Extra 中记录保存结果
+func (m *QRSessionManager) persistCookie(session QRSession, result *model.QRLoginResult, cookie string) {
+	cookieSource := QRLoginCookieSource(session.Source)
+	result.Cookie = cookie
+	target, err := m.SaveCookie(session, cookieSource, cookie)
+	m.recordSave(session.Source, session.Key, cookie, target, err)
+	if err == nil && target == "" {
+		return
+	}
+	if result.Extra == nil {
+		result.Extra = make(map[string]string)
+	}
+	result.Extra["cookie_source"] = cookieSource
+	result.Extra["cookie_length"] = strconv.Itoa(len(cookie))
+	if err != nil {
+		slog.Error("save qr login cookie failed", "source", session.Source, "created_by", session.CreatedBy, "error", err.Error())
+		result.Extra["cookie_saved"] = "false"
+		result.Extra["cookie_error"] = err.Error()
+		return
+	}
+	result.Extra["cookie_saved"] = "true"
+	result.Extra["cookie_target"] = target
+}
+
+// recordSave 记录 Cookie 的保存结果，失败时保留 Cookie 供之后重新保存
+func (m *QRSessionManager) recordSave(source, key, cookie, target string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sessions[qrSessionKey(source, key)]
+	if s == nil {
+		return
+	}
+	s.UpdatedAt = time.Now()
+	if err != nil {
+		s.cookie = cookie
+		s.CookieSaved = false
+		s.CookieError = err.Error()
+		return
+	}
+	s.cookie = ""
+	s.CookieSaved = target != ""
+	s.CookieError = ""
+}
+
+// QRLoginCookie 将登录结果中的 Cookie 整理为请求头格式
+func QRLoginCookie(result *model.QRLoginResult) string {
+	if result == nil {
+		return ""
+	}
+	if cookie := strings.TrimSpace(result.Cookie); cookie != "" {
+		return cookie
+	}
+	if len(result.Cookies) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(result.Cookies))
+	for key := range result.Cookies {
+		if strings.TrimSpace(key) != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.TrimSpace(result.Cookies[key])
+		if value != "" {
+			parts = append(parts, key+"="+value)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// QRLoginCookieSource 扫码登录平台对应的 Cookie 平台，QQ 微信扫码的 Cookie 归入 qq
+func QRLoginCookieSource(source string) string {
+	if source == "qq_wx" {
+		return "qq"
+	}
+	return source
+}
+
+// SaveCookie 按会话创建者保存登录成功得到的 Cookie，返回保存位置 user、global，未保存时为空串
+func (m *QRSessionManager) SaveCookie(s QRSession, source, cookie string) (string, error) {
+	switch {
+	case s.User != "":
+		if err := US.SetVaultCookies(s.User, map[string]string{source: cookie}); err != nil {
+			return "", err
+		}
+		return "user", nil
+	case s.Admin:
+		CM.SetAll(map[string]string{source: cookie})
+		return "global", CM.Save()
+	default:
+		return "", nil
+	}
+}
+
+// expire 将超过有效期且未结束的会话标记为过期，调用方需持有锁
+func (m *QRSessionManager) expire(s *QRSession, now time.Time) {
+	if !s.Terminal() && now.After(s.ExpiresAt) {
+		s.State = QRStateExpired
+		s.UpdatedAt = s.ExpiresAt
+		s.cookie = ""
+	}
+}
+
+// prune 清理过期超过保留时长的会话，调用方需持有锁
+func (m *QRSessionManager) prune(now time.Time) {
+	for k, s := range m.sessions {
+		if now.After(s.ExpiresAt.Add(qrSessionRetention)) {
+			delete(m.sessions, k)
+		}
+	}
+}
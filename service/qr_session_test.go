@@ -0,0 +1,51 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/guohuiyuan/music-lib/model"
+)
+
+func TestQRSessionKeepsUnsavedCookie(t *testing.T) {
+	old := US
+	US = openTestUserStore(t, filepath.Join(t.TempDir(), "users.db"), nil)
+	t.Cleanup(func() { US = old })
+
+	m := &QRSessionManager{sessions: make(map[string]*QRSession), watches: make(map[string]*qrWatch)}
+	session, err := m.Create("netease", &model.QRLoginSession{Key: "k"}, "user:alice", "alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Update("netease", "k", string(model.QRLoginStatusSuccess))
+
+	// 用户尚未注册，保存失败
+	result := &model.QRLoginResult{Status: model.QRLoginStatusSuccess}
+	m.persistCookie(session, result, "MUSIC_U=1")
+	if result.Extra["cookie_saved"] != "false" || result.Extra["cookie_error"] == "" {
+		t.Fatalf("extra = %v, want cookie_saved=false with error", result.Extra)
+	}
+	session, _ = m.Get("netease", "k")
+	if session.CookieSaved || session.CookieError == "" || session.Terminal() {
+		t.Fatalf("session after failed save = %+v, want unsaved and not terminal", session)
+	}
+
+	// 之后的轮询不访问平台，直接重新保存
+	if _, err := US.Register("alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	result, err = m.Check(t.Context(), "netease", "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Extra["cookie_saved"] != "true" || result.Extra["cookie_target"] != "user" {
+		t.Fatalf("extra = %v, want cookie saved to user", result.Extra)
+	}
+	session, _ = m.Get("netease", "k")
+	if !session.CookieSaved || session.CookieError != "" || !session.Terminal() {
+		t.Fatalf("session after retry = %+v, want saved and terminal", session)
+	}
+	if got := US.VaultCookies("alice")["netease"]; got != "MUSIC_U=1" {
+		t.Fatalf("vault cookie = %q", got)
+	}
+}
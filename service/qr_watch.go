@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+	"github.com/guohuiyuan/music-lib/model"
+)
+
+// QRStateError 服务端轮询平台失败，会话本身仍然有效，轮询会继续
+const QRStateError = "error"
+
+// QREvent 推送给订阅者的扫码登录状态变化
+type QREvent struct {
+	Source   string               `json:"source"`
+	Key      string               `json:"key"`
+	State    string               `json:"state"`
+	Message  string               `json:"message,omitempty"`
+	Terminal bool                 `json:"terminal"` // 为 true 时会话已结束，推送随之关闭
+	Result   *model.QRLoginResult `json:"result,omitempty"`
+	Time     time.Time            `json:"time"`
+}
+
+// qrWatch 单个会话的服务端轮询，所有订阅者共享同一个轮询
+type qrWatch struct {
+	subs map[chan QREvent]struct{}
+	last QREvent
+}
+
+// Subscribe 订阅会话的状态变化，首个事件为当前状态；会话没有轮询时启动一个。
+// 会话结束后通道关闭，调用方断开时需调用返回的取消函数，最后一个订阅者离开后轮询停止
+func (m *QRSessionManager) Subscribe(source, key string) (<-chan QREvent, func(), error) {
+	session, err := m.Get(source, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan QREvent, 16)
+	k := qrSessionKey(source, key)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.watches[k]
+	if w == nil {
+		current := QREvent{Source: source, Key: key, State: session.State, Terminal: session.Terminal(), Time: session.UpdatedAt}
+		if current.Terminal {
+			ch <- current
+			close(ch)
+			return ch, func() {}, nil
+		}
+		w = &qrWatch{subs: make(map[chan QREvent]struct{}), last: current}
+		m.watches[k] = w
+		go m.poll(source, key, w)
+	}
+	ch <- w.last
+	w.subs[ch] = struct{}{}
+	cancel := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel, nil
+}
+
+// poll 按 qr_login.poll_interval_seconds 轮询平台，状态变化时推送给订阅者，会话结束或无人订阅时退出
+func (m *QRSessionManager) poll(source, key string, w *qrWatch) {
+	ticker := time.NewTicker(time.Duration(config.C.QRLogin.PollIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !m.watching(source, key, w) {
+			return
+		}
+		ev := QREvent{Source: source, Key: key, Time: time.Now()}
+		result, err := m.Check(context.Background(), source, key)
+		switch {
+		case errors.Is(err, ErrQRSessionExpired):
+			ev.State = QRStateExpired
+			ev.Terminal = true
+		case errors.Is(err, ErrNotFound):
+			ev.State = QRStateExpired
+			ev.Message = err.Error()
+			ev.Terminal = true
+		case err != nil:
+			ev.State = QRStateError
+			ev.Message = err.Error()
+		case result == nil:
+			continue
+		default:
+			ev.State = string(result.Status)
+			ev.Result = result
+			ev.Terminal = (&QRSession{State: ev.State}).Terminal()
+			if result.Extra["cookie_saved"] == "false" {
+				// Cookie 保存失败时不结束推送，之后的轮询会重新保存
+				ev.Message = "cookie not saved: " + result.Extra["cookie_error"]
+				ev.Terminal = false
+			}
+		}
+		if !m.publish(source, key, w, ev) {
+			return
+		}
+	}
+}
+
+// watching 检查轮询是否仍有订阅者，没有时移除轮询
+func (m *QRSessionManager) watching(source, key string, w *qrWatch) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(w.subs) > 0 {
+		return true
+	}
+	m.stopWatch(qrSessionKey(source, key), w)
+	return false
+}
+
+// publish 向订阅者推送状态变化，相同状态不重复推送；会话结束时关闭全部订阅并返回 false
+func (m *QRSessionManager) publish(source, key string, w *qrWatch, ev QREvent) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ev.State == w.last.State && ev.Message == w.last.Message && !ev.Terminal {
+		return true
+	}
+	w.last = ev
+	for ch := range w.subs {
+		select {
+		case ch <- ev:
+		default: // 订阅者读取过慢时丢弃中间状态，结束事件前会腾出位置
+			if ev.Terminal {
+				select {
+				case <-ch:
+				default:
+				}
+				select {
+				case ch <- ev:
+				default:
+				}
+			}
+		}
+	}
+	if ev.Terminal {
+		for ch := range w.subs {
+			delete(w.subs, ch)
+			close(ch)
+		}
+		m.stopWatch(qrSessionKey(source, key), w)
+		return false
+	}
+	return true
+}
+
+// stopWatch 移除会话的轮询，调用方需持有锁
+func (m *QRSessionManager) stopWatch(k string, w *qrWatch) {
+	if m.watches[k] == w {
+		delete(m.watches, k)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// TokenBucket 令牌桶限流器，rate 为每秒补充的令牌数
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Reserve 预占一个令牌并返回需要等待的时间；等待时间超过 maxWait 时不预占并返回 false
+func (b *TokenBucket) Reserve(maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// Cancel 归还 Reserve 预占但最终未使用的令牌，排在其后的预占者随之可以提前获得令牌
+func (b *TokenBucket) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+1, b.burst)
+}
+
+var errOutboundLimited = errors.New("outbound request limit exceeded")
+
+// sourceLimiter 按平台限制服务对上游的请求速率，避免共享 Cookie 因请求过快被封禁
+type sourceLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*TokenBucket
+}
+
+var outboundLimiter = &sourceLimiter{buckets: make(map[string]*TokenBucket)}
+
+func (l *sourceLimiter) bucket(source string) *TokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[source]; ok {
+		return b
+	}
+	cfg := config.C.RateLimit.Outbound
+	rule, ok := cfg.Sources[source]
+	if !ok {
+		rule = cfg.Default
+	}
+	var b *TokenBucket
+	if rule.Rate > 0 {
+		b = NewTokenBucket(rule.Rate, rule.Burst)
+	}
+	l.buckets[source] = b
+	return b
+}
+
+// wait 等待该平台的出站令牌，超过配置的最长等待时间时返回上游限流错误；
+// 等待期间所属请求被取消或超时时归还预占的令牌，并立即返回 ctx 的错误
+func (l *sourceLimiter) wait(ctx context.Context, source string) error {
+	b := l.bucket(source)
+	if b == nil {
+		return nil
+	}
+	maxWait := time.Duration(config.C.RateLimit.Outbound.MaxWaitMs) * time.Millisecond
+	wait, ok := b.Reserve(maxWait)
+	if !ok {
+		return NewSourceError(source, ErrRateLimited, errOutboundLimited)
+	}
+	if !sleepContext(ctx, wait) {
+		b.Cancel()
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	b := NewTokenBucket(2, 2) // 每 500ms 补充一个令牌
+	tests := []struct {
+		name    string
+		maxWait time.Duration
+		ok      bool
+		wait    time.Duration
+	}{
+		{"first burst token", 0, true, 0},
+		{"second burst token", 0, true, 0},
+		{"empty bucket without waiting", 0, false, 500 * time.Millisecond},
+		{"empty bucket within max wait", time.Second, true, 500 * time.Millisecond},
+		{"queued behind reservation", 800 * time.Millisecond, false, time.Second},
+	}
+	for _, tt := range tests {
+		wait, ok := b.Reserve(tt.maxWait)
+		if ok != tt.ok {
+			t.Fatalf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+		// 两次调用之间经过的时间会略微缩短等待
+		if wait > tt.wait || wait < tt.wait-50*time.Millisecond {
+			t.Fatalf("%s: wait = %v, want about %v", tt.name, wait, tt.wait)
+		}
+	}
+}
+
+func TestSourceLimiterWaitHonoursContext(t *testing.T) {
+	old := config.C.RateLimit.Outbound.MaxWaitMs
+	t.Cleanup(func() { config.C.RateLimit.Outbound.MaxWaitMs = old })
+	config.C.RateLimit.Outbound.MaxWaitMs = 10000
+
+	l := &sourceLimiter{buckets: map[string]*TokenBucket{"qq": NewTokenBucket(0.1, 1)}}
+	if err := l.wait(context.Background(), "qq"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.wait(ctx, "qq"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("wait ignored the context for %v", elapsed)
+	}
+	// 取消的等待归还了预占的令牌，下一个调用方只需等待第一个令牌补充的时间
+	if wait, ok := l.buckets["qq"].Reserve(time.Minute); !ok || wait > 10*time.Second {
+		t.Fatalf("next reservation wait = %v, %v; want at most 10s after the refund", wait, ok)
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// BuildRequest 构造带有 Cookie 和平台请求头(防盗链)的 Request，串流代理与可播放探测共用
+func BuildRequest(ctx context.Context, method, urlStr, source, rangeHeader string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	ApplyHeaders(req, source)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	if cookie := CookieFor(ctx, source); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+	return req, nil
+}
+
+// FetchCover 按封面域名所属平台的请求头拉取封面图，不附带 Cookie。
+// 上游返回非 200 时在错误之外同时返回响应体，供兼容路由沿用旧行为原样转发
+func FetchCover(ctx context.Context, urlStr string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	source := SourceForHost(req.URL.Hostname())
+	ApplyHeaders(req, source)
+	resp, err := Stream(source, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return data, NewSourceError(source, statusErrorKind(resp.StatusCode), fmt.Errorf("cover returned status %d", resp.StatusCode))
+	}
+	return data, nil
+}
+
+// ProbeURL 以 Range 请求探测音频直链的前两个字节，返回上游状态码与响应，调用方负责关闭 Body
+func ProbeURL(ctx context.Context, source, urlStr string) (*http.Response, error) {
+	req, err := BuildRequest(ctx, "GET", urlStr, source, "bytes=0-1")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, seconds(config.C.HTTP.ProbeTimeoutSeconds))
+	resp, err := HTTPClient(source).Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// Playable 判断探测响应是否表示音频可以播放
+func Playable(resp *http.Response) bool {
+	return resp.StatusCode == 200 || resp.StatusCode == 206
+}
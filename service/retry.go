@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// nonIdempotent 重复执行会产生副作用的能力，无论配置如何都不重试
+var nonIdempotent = map[string]bool{
+	CapQRLoginCreate: true, // 每次创建都会生成新的二维码会话
+}
+
+var transientErrorHints = []string{"connection reset", "connection refused", "broken pipe", "status 5", "status code 5", "bad gateway", "service unavailable", "gateway timeout"}
+
+// retryPolicy 返回能力的重试策略，不可重试的能力只执行一次
+func retryPolicy(capability string) config.RetryPolicy {
+	if nonIdempotent[capability] {
+		return config.RetryPolicy{MaxAttempts: 1}
+	}
+	return config.C.Retry.Policy(capability)
+}
+
+// retryable 判断错误是否为值得重试的瞬时错误：超时、上游限流、连接中断与 5xx。
+// 服务自身的出站限流与熔断不重试，资源不存在、需要登录与付费内容重试也不会成功
+func retryable(err error) bool {
+	if errors.Is(err, errOutboundLimited) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	switch classifyError(err) {
+	case ErrTimeout, ErrRateLimited:
+		return true
+	case ErrNotFound, ErrAuthRequired, ErrPaidContent:
+		return false
+	}
+	// *url.Error 本身实现了 net.Error，需按其包装的底层错误判断：
+	// 证书校验失败、DNS 解析失败、协议不支持等永久错误重试也不会成功。
+	// 连接在响应完成前被关闭时，底层错误为 io.EOF 或 io.ErrUnexpectedEOF；
+	// 不按文本匹配 "eof"，上游返回残缺 JSON 时的解码错误不属于传输错误
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return transientNetError(urlErr.Err) || errors.Is(urlErr.Err, io.EOF) || errors.Is(urlErr.Err, io.ErrUnexpectedEOF)
+	}
+	return transientNetError(err) || containsAny(strings.ToLower(err.Error()), transientErrorHints)
+}
+
+// transientNetError 判断网络错误是否为瞬时错误：超时，或连接被重置、被拒绝
+func transientNetError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// retryDelay 计算第 attempt 次失败后的退避时间：指数增长并封顶，再在 [d/2, d] 内随机抖动
+func retryDelay(policy config.RetryPolicy, attempt int) time.Duration {
+	d := time.Duration(policy.BaseDelayMs) * time.Millisecond << (attempt - 1)
+	if maxDelay := time.Duration(policy.MaxDelayMs) * time.Millisecond; d > maxDelay || d <= 0 {
+		d = maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+func TestRetryPolicyIdempotency(t *testing.T) {
+	if got := retryPolicy(CapQRLoginCreate).MaxAttempts; got != 1 {
+		t.Errorf("qr_create attempts = %d, want 1", got)
+	}
+	if got, want := retryPolicy(CapSearch).MaxAttempts, config.C.Retry.Default.MaxAttempts; got != want {
+		t.Errorf("search attempts = %d, want %d", got, want)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", NewSourceError("qq", ErrTimeout, nil), true},
+		{"upstream rate limit", errors.New("status 429"), true},
+		{"5xx", errors.New("status 503"), true},
+		{"connection reset", errors.New("read tcp: connection reset by peer"), true},
+		{"transport EOF", &url.Error{Op: "Get", URL: "https://example.com", Err: io.EOF}, true},
+		{"transport unexpected EOF", &url.Error{Op: "Get", URL: "https://example.com", Err: io.ErrUnexpectedEOF}, true},
+		{"transport connection reset", &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true},
+		{"transport unknown authority", &url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}}, false},
+		{"transport unsupported scheme", &url.Error{Op: "Get", URL: "ftp://example.com", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{"transport DNS failure", &url.Error{Op: "Get", URL: "https://example.invalid", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}, false},
+		{"truncated JSON body", fmt.Errorf("decode response: %w", io.ErrUnexpectedEOF), false},
+		{"EOF in message", errors.New("unexpected EOF"), false},
+		{"not found", NewSourceError("qq", ErrNotFound, nil), false},
+		{"auth required", NewSourceError("qq", ErrAuthRequired, nil), false},
+		{"paid content", errors.New("vip only"), false},
+		{"circuit open", NewSourceError("qq", ErrCircuitOpen, nil), false},
+		{"outbound limited", NewSourceError("qq", ErrRateLimited, errOutboundLimited), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := config.RetryPolicy{MaxAttempts: 5, BaseDelayMs: 100, MaxDelayMs: 300}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 300 * time.Millisecond},
+		{10, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := retryDelay(policy, tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Fatalf("retryDelay(%d) = %v, want within [%v, %v]", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
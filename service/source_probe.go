@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+// 合成探测的阶段，按顺序执行，前一阶段失败时后续阶段跳过
+const (
+	ProbeStageSearch      = "search"
+	ProbeStageDownloadURL = "download_url"
+	ProbeStagePlayable    = "playable"
+)
+
+// 探测结果状态
+const (
+	ProbeStatusPending  = "pending"  // 尚未探测
+	ProbeStatusOK       = "ok"       // 全部阶段通过
+	ProbeStatusDegraded = "degraded" // 搜索可用，但获取链接或播放失败
+	ProbeStatusDown     = "down"     // 搜索失败
+	ProbeStatusDisabled = "disabled" // 配置中关闭了该平台的探测
+
+	ProbeStageFailed  = "failed"
+	ProbeStageSkipped = "skipped"
+)
+
+// ProbeStageResult 单个探测阶段的结果
+type ProbeStageResult struct {
+	Stage      string `json:"stage"`
+	Status     string `json:"status"`             // ok、failed 或 skipped
+	Category   string `json:"category,omitempty"` // 失败类型，与指标中的 result 标签一致
+	Error      string `json:"error,omitempty"`
+	HTTPStatus int    `json:"http_status,omitempty"` // playable 阶段上游返回的状态码
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SourceProbeResult 单个平台最近一次合成探测的结果
+type SourceProbeResult struct {
+	Source      string             `json:"source"`
+	Status      string             `json:"status"`
+	FailedStage string             `json:"failed_stage,omitempty"`
+	Keyword     string             `json:"keyword,omitempty"`
+	SongID      string             `json:"song_id,omitempty"` // 搜索命中并用于后续阶段的歌曲
+	Stages      []ProbeStageResult `json:"stages,omitempty"`
+	Circuit     string             `json:"circuit"` // 熔断器当前状态：closed、open 或 half_open
+	LastChecked time.Time          `json:"last_checked,omitzero"`
+	LastOK      time.Time          `json:"last_ok,omitzero"` // 最近一次全部阶段通过的时间
+}
+
+// SourceProber 定期对各平台执行合成探测，区分搜索失效与链接/播放失效
+type SourceProber struct {
+	mu      sync.RWMutex
+	results map[string]*SourceProbeResult
+	once    sync.Once
+}
+
+var SP = &SourceProber{results: make(map[string]*SourceProbeResult)}
+
+// Start 启动后台探测，间隔由 health.probe_minutes 配置，负数表示关闭
+func (p *SourceProber) Start() {
+	minutes := config.C.Health.ProbeMinutes
+	if minutes < 0 {
+		return
+	}
+	p.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Duration(minutes) * time.Minute)
+			defer ticker.Stop()
+			for {
+				p.ProbeAll()
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// ProbeAll 立即并发探测全部平台
+func (p *SourceProber) ProbeAll() {
+	var wg sync.WaitGroup
+	for _, source := range GetAllSourceNames() {
+		wg.Add(1)
+		go func(source string) {
+			defer wg.Done()
+			p.Probe(source)
+		}(source)
+	}
+	wg.Wait()
+}
+
+// Probe 立即探测单个平台并记录结果
+func (p *SourceProber) Probe(source string) SourceProbeResult {
+	cfg := config.C.Health.Probe(source)
+	result := SourceProbeResult{Source: source, Keyword: cfg.Keyword, LastChecked: time.Now()}
+	if cfg.Disabled {
+		result.Status = ProbeStatusDisabled
+		result.Keyword = ""
+	} else {
+		p.run(&result)
+	}
+
+	p.mu.Lock()
+	prev := p.results[source]
+	if result.Status == ProbeStatusOK {
+		result.LastOK = result.LastChecked
+	} else if prev != nil {
+		result.LastOK = prev.LastOK
+	}
+	p.results[source] = &result
+	p.mu.Unlock()
+
+	if result.FailedStage != "" && (prev == nil || prev.FailedStage != result.FailedStage) {
+		slog.Warn("source probe failed", "source", source, "stage", result.FailedStage, "status", result.Status)
+	}
+	return result
+}
+
+// run 依次执行各阶段。探测是诊断调用，不经熔断与出站限流：平台熔断期间仍会实际访问上游，
+// 运维据此确认平台是否已恢复，探测结果也不会改变熔断状态
+func (p *SourceProber) run(result *SourceProbeResult) {
+	ctx := withDiagnostic(context.Background())
+	source := result.Source
+	fail := func(stage ProbeStageResult, err error) {
+		stage.Status = ProbeStageFailed
+		stage.Category = UpstreamResult(err)
+		stage.Error = err.Error()
+		result.Stages = append(result.Stages, stage)
+		result.FailedStage = stage.Stage
+	}
+
+	// 1. 搜索
+	stage := ProbeStageResult{Stage: ProbeStageSearch}
+	search := GetSearchFunc(ctx, source)
+	if search == nil {
+		stage.Status = ProbeStageSkipped
+		result.Stages = append(result.Stages, stage)
+		result.Status = ProbeStatusOK
+		return
+	}
+	start := time.Now()
+	songs, err := search(result.Keyword)
+	stage.DurationMs = time.Since(start).Milliseconds()
+	if err == nil && len(songs) == 0 {
+		err = NewSourceError(source, ErrNotFound, errors.New("search returned no results"))
+	}
+	if err != nil {
+		fail(stage, err)
+		result.Status = ProbeStatusDown
+		p.skip(result, ProbeStageDownloadURL, ProbeStagePlayable)
+		return
+	}
+	stage.Status = ProbeStatusOK
+	result.Stages = append(result.Stages, stage)
+	song := songs[0]
+	result.SongID = song.ID
+
+	// 2. 获取下载链接
+	stage = ProbeStageResult{Stage: ProbeStageDownloadURL}
+	download := GetDownloadFunc(ctx, source)
+	if download == nil {
+		stage.Status = ProbeStageSkipped
+		result.Stages = append(result.Stages, stage)
+		result.Status = ProbeStatusOK
+		p.skip(result, ProbeStagePlayable)
+		return
+	}
+	start = time.Now()
+	urlStr, err := download(&song)
+	stage.DurationMs = time.Since(start).Milliseconds()
+	if err == nil && urlStr == "" {
+		err = NewSourceError(source, ErrNotFound, errors.New("empty download url"))
+	}
+	if err != nil {
+		fail(stage, err)
+		result.Status = ProbeStatusDegraded
+		p.skip(result, ProbeStagePlayable)
+		return
+	}
+	stage.Status = ProbeStatusOK
+	result.Stages = append(result.Stages, stage)
+
+	// 3. Range 探测，汽水音乐需要解密、5sing 不支持 Range，与换源校验一致跳过
+	result.Status = ProbeStatusOK
+	if source == "soda" || source == "fivesing" {
+		p.skip(result, ProbeStagePlayable)
+		return
+	}
+	stage = ProbeStageResult{Stage: ProbeStagePlayable}
+	start = time.Now()
+	resp, err := ProbeURL(ctx, source, urlStr)
+	stage.DurationMs = time.Since(start).Milliseconds()
+	if err == nil {
+		resp.Body.Close()
+		stage.HTTPStatus = resp.StatusCode
+		if !Playable(resp) {
+			err = NewSourceError(source, statusErrorKind(resp.StatusCode), fmt.Errorf("range probe returned status %d", resp.StatusCode))
+		}
+	}
+	if err != nil {
+		fail(stage, err)
+		result.Status = ProbeStatusDegraded
+		return
+	}
+	stage.Status = ProbeStatusOK
+	result.Stages = append(result.Stages, stage)
+}
+
+func (p *SourceProber) skip(result *SourceProbeResult, stages ...string) {
+	for _, stage := range stages {
+		result.Stages = append(result.Stages, ProbeStageResult{Stage: stage, Status: ProbeStageSkipped})
+	}
+}
+
+// statusErrorKind 按上游 HTTP 状态码推断错误类型
+func statusErrorKind(status int) error {
+	switch status {
+	case 401:
+		return ErrAuthRequired
+	case 404:
+		return ErrNotFound
+	case 429:
+		return ErrRateLimited
+	default:
+		return ErrUpstream
+	}
+}
+
+// Results 返回各平台最近一次探测结果，尚未探测的平台为 pending
+func (p *SourceProber) Results() []SourceProbeResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result := make([]SourceProbeResult, 0, len(GetAllSourceNames()))
+	for _, source := range GetAllSourceNames() {
+		circuit := CB.State(source).State
+		if r := p.results[source]; r != nil {
+			item := *r
+			item.Circuit = circuit
+			result = append(result, item)
+			continue
+		}
+		status := ProbeStatusPending
+		if config.C.Health.Probe(source).Disabled {
+			status = ProbeStatusDisabled
+		}
+		result = append(result, SourceProbeResult{Source: source, Status: status, Circuit: circuit})
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+const (
+	UA_Common = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/134.0.0.0 Safari/537.36"
+	UA_Mobile = "Mozilla/5.0 (iPhone; CPU iPhone OS 9_1 like Mac OS X) AppleWebKit/601.1.46 (KHTML, like Gecko) Version/9.0 Mobile/13B143 Safari/601.1"
+)
+
+// sourceProfile 平台的静态信息
+type sourceProfile struct {
+	// hosts 接口与音频 CDN 的域名，按后缀匹配识别请求所属平台；第一个域名用于出口连通性测试
+	hosts []string
+	// headers 访问音频直链与封面时的默认请求头，可被 source_headers 配置覆盖
+	headers config.HeaderProfile
+}
+
+var sourceProfiles = map[string]sourceProfile{
+	"netease": {
+		hosts:   []string{"music.163.com", "163.com", "126.net"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "http://music.163.com/"},
+	},
+	"qq": {
+		hosts:   []string{"y.qq.com", "qq.com", "gtimg.cn", "qpic.cn"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "http://y.qq.com"},
+	},
+	"kugou": {
+		hosts:   []string{"www.kugou.com", "kugou.com"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "https://www.kugou.com/"},
+	},
+	"kuwo": {
+		hosts:   []string{"www.kuwo.cn", "kuwo.cn"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "https://www.kuwo.cn/"},
+	},
+	"migu": {
+		hosts:   []string{"music.migu.cn", "migu.cn"},
+		headers: config.HeaderProfile{UserAgent: UA_Mobile, Referer: "http://music.migu.cn/"},
+	},
+	"fivesing": {
+		hosts:   []string{"5sing.kugou.com"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "http://5sing.kugou.com/"},
+	},
+	"jamendo": {
+		hosts:   []string{"api.jamendo.com", "jamendo.com"},
+		headers: config.HeaderProfile{UserAgent: UA_Common},
+	},
+	"joox": {
+		hosts:   []string{"api.joox.com", "joox.com"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "https://www.joox.com/"},
+	},
+	"qianqian": {
+		hosts:   []string{"music.taihe.com", "taihe.com", "qianqian.com"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "https://music.taihe.com/"},
+	},
+	"soda": {
+		hosts:   []string{"api.qishui.com", "qishui.com", "douyinvod.com"},
+		headers: config.HeaderProfile{UserAgent: UA_Common},
+	},
+	"bilibili": {
+		hosts:   []string{"api.bilibili.com", "bilibili.com", "bilivideo.com", "bilivideo.cn", "hdslb.com"},
+		headers: config.HeaderProfile{UserAgent: UA_Common, Referer: "https://www.bilibili.com/"},
+	},
+}
+
+// HeaderProfileFor 返回平台实际使用的请求头：内置默认值之上叠加 source_headers 中的配置，
+// 未知平台只带通用 User-Agent
+func HeaderProfileFor(source string) config.HeaderProfile {
+	p, ok := sourceProfiles[source]
+	profile := p.headers
+	if !ok {
+		profile = config.HeaderProfile{UserAgent: UA_Common}
+	}
+	profile.Headers = maps.Clone(profile.Headers)
+	override, ok := config.C.Headers[source]
+	if !ok {
+		return profile
+	}
+	if override.UserAgent != "" {
+		profile.UserAgent = override.UserAgent
+	}
+	if override.Referer != "" {
+		profile.Referer = override.Referer
+	}
+	if len(override.Headers) > 0 && profile.Headers == nil {
+		profile.Headers = make(map[string]string, len(override.Headers))
+	}
+	maps.Copy(profile.Headers, override.Headers)
+	return profile
+}
+
+// ApplyHeaders 按平台的请求头配置设置 User-Agent、Referer 与其他请求头
+func ApplyHeaders(req *http.Request, source string) {
+	profile := HeaderProfileFor(source)
+	if profile.UserAgent != "" {
+		req.Header.Set("User-Agent", profile.UserAgent)
+	}
+	if profile.Referer != "" {
+		req.Header.Set("Referer", profile.Referer)
+	}
+	for k, v := range profile.Headers {
+		if v == "" {
+			req.Header.Del(k)
+		} else {
+			req.Header.Set(k, v)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SubsonicIDFile Subsonic ID 元数据的嵌入式数据库(bbolt)，重启后客户端缓存的 ID 仍可解析
+const SubsonicIDFile = "subsonic_ids.db"
+
+const (
+	subsonicIDCacheSize     = 10000                // 内存中缓存的 ID 数
+	subsonicIDFlushInterval = time.Second          // 新 ID 批量写库的间隔
+	subsonicIDTouchInterval = 24 * time.Hour       // 元数据未变化时，最近出现时间的记录粒度
+	subsonicIDRetention     = 180 * 24 * time.Hour // 超过该时长未再出现在结果中的 ID 在启动时清理
+)
+
+var subsonicIDsBucket = []byte("ids")
+
+// SubsonicRef Subsonic ID 对应的元数据。平台没有按 ID 查询单曲详情的接口，
+// 串流、歌词回退、封面与 getSong 所需的信息在生成 ID 时保存
+type SubsonicRef struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name,omitempty"`
+	Artist   string            `json:"artist,omitempty"`
+	Album    string            `json:"album,omitempty"`
+	AlbumID  string            `json:"album_id,omitempty"`
+	Duration int               `json:"duration,omitempty"`
+	Cover    string            `json:"cover,omitempty"`
+	Ext      string            `json:"ext,omitempty"`
+	Extra    map[string]string `json:"extra,omitempty"`
+	Seen     time.Time         `json:"seen"` // 最近一次出现在搜索或列表结果中的时间
+}
+
+// sameAs 比较元数据是否相同，不含 Seen
+func (r SubsonicRef) sameAs(o SubsonicRef) bool {
+	return r.ID == o.ID && r.Name == o.Name && r.Artist == o.Artist && r.Album == o.Album && r.AlbumID == o.AlbumID &&
+		r.Duration == o.Duration && r.Cover == o.Cover && r.Ext == o.Ext && maps.Equal(r.Extra, o.Extra)
+}
+
+// SubsonicIDStore 保存 Subsonic ID 的元数据：最近使用的 ID 缓存在内存中，
+// 新增与变化的条目按 subsonicIDFlushInterval 批量写入数据库，未加载数据库时只使用内存缓存
+type SubsonicIDStore struct {
+	db    *bolt.DB
+	cache *LRU[SubsonicRef]
+	once  sync.Once
+
+	mu      sync.Mutex
+	pending map[string]SubsonicRef
+}
+
+var SIDS = &SubsonicIDStore{
+	cache:   NewLRU[SubsonicRef]("subsonic_ids", subsonicIDCacheSize),
+	pending: make(map[string]SubsonicRef),
+}
+
+// Load 打开 ID 数据库并启动批量写入，需在处理请求前调用
+func (s *SubsonicIDStore) Load() error {
+	if err := s.open(SubsonicIDFile); err != nil {
+		return fmt.Errorf("load %s: %w", SubsonicIDFile, err)
+	}
+	s.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(subsonicIDFlushInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := s.Flush(); err != nil {
+					slog.Warn("save subsonic ids failed", "error", err.Error())
+				}
+			}
+		}()
+	})
+	return nil
+}
+
+// open 打开数据库并清理超过保留时长的 ID
+func (s *SubsonicIDStore) open(path string) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-subsonicIDRetention)
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(subsonicIDsBucket)
+		if err != nil {
+			return err
+		}
+		var stale [][]byte
+		err = b.ForEach(func(k, v []byte) error {
+			var ref SubsonicRef
+			if json.Unmarshal(v, &ref) != nil || ref.Seen.Before(cutoff) {
+				stale = append(stale, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	s.mu.Lock()
+	s.db = db
+	s.mu.Unlock()
+	return nil
+}
+
+// Close 写入尚未保存的 ID 并关闭数据库
+func (s *SubsonicIDStore) Close() error {
+	err := s.Flush()
+	s.mu.Lock()
+	db := s.db
+	s.db = nil
+	s.mu.Unlock()
+	if db == nil {
+		return err
+	}
+	return errors.Join(err, db.Close())
+}
+
+// Put 记录 ID 的元数据；元数据未变化且在 subsonicIDTouchInterval 内记录过时不再写库
+func (s *SubsonicIDStore) Put(id string, ref SubsonicRef) {
+	now := time.Now()
+	ref.Seen = now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.cache.Peek(id); ok && cached.sameAs(ref) && now.Sub(cached.Seen) < subsonicIDTouchInterval {
+		return
+	}
+	s.cache.Add(id, ref)
+	if s.db != nil {
+		s.pending[id] = ref
+	}
+}
+
+// Get 返回 ID 的元数据，内存中没有时从数据库读取
+func (s *SubsonicIDStore) Get(id string) (SubsonicRef, bool) {
+	if ref, ok := s.cache.Get(id); ok {
+		return ref, true
+	}
+	s.mu.Lock()
+	db := s.db
+	s.mu.Unlock()
+	if db == nil {
+		return SubsonicRef{}, false
+	}
+	var ref SubsonicRef
+	var found bool
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(subsonicIDsBucket).Get([]byte(id))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &ref)
+	})
+	if err != nil || !found {
+		return SubsonicRef{}, false
+	}
+	s.cache.Add(id, ref)
+	return ref, true
+}
+
+// Flush 将待写入的 ID 在一个事务中写库，失败的条目留待下次写入
+func (s *SubsonicIDStore) Flush() error {
+	s.mu.Lock()
+	db, pending := s.db, s.pending
+	if db == nil || len(pending) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	s.pending = make(map[string]SubsonicRef)
+	s.mu.Unlock()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(subsonicIDsBucket)
+		for id, ref := range pending {
+			data, err := json.Marshal(ref)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.mu.Lock()
+		for id, ref := range pending {
+			if _, ok := s.pending[id]; !ok {
+				s.pending[id] = ref
+			}
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestSubsonicIDStore(t *testing.T, path string) *SubsonicIDStore {
+	t.Helper()
+	s := &SubsonicIDStore{cache: NewLRU[SubsonicRef]("test", 10), pending: make(map[string]SubsonicRef)}
+	if err := s.open(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSubsonicIDStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subsonic_ids.db")
+	s := newTestSubsonicIDStore(t, path)
+	ref := SubsonicRef{ID: "123", Name: "Song", Cover: "https://p1.music.126.net/a.jpg", Extra: map[string]string{"hash": "abc"}}
+	s.Put("tr:netease:123", ref)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = newTestSubsonicIDStore(t, path)
+	got, ok := s.Get("tr:netease:123")
+	if !ok || !got.sameAs(ref) {
+		t.Fatalf("Get after reopen = %+v, %v; want %+v", got, ok, ref)
+	}
+	if _, ok := s.Get("tr:netease:456"); ok {
+		t.Fatal("unknown id resolved")
+	}
+}
+
+func TestSubsonicIDStorePrunesStaleIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subsonic_ids.db")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(subsonicIDsBucket)
+		if err != nil {
+			return err
+		}
+		for id, seen := range map[string]time.Time{
+			"tr:qq:fresh": time.Now(),
+			"tr:qq:stale": time.Now().Add(-subsonicIDRetention - time.Hour),
+		} {
+			data, _ := json.Marshal(SubsonicRef{ID: id, Seen: seen})
+			if err := b.Put([]byte(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestSubsonicIDStore(t, path)
+	if _, ok := s.Get("tr:qq:fresh"); !ok {
+		t.Error("fresh id pruned")
+	}
+	if _, ok := s.Get("tr:qq:stale"); ok {
+		t.Error("stale id kept")
+	}
+}
+
+func TestSubsonicIDStoreSkipsUnchangedWrites(t *testing.T) {
+	s := newTestSubsonicIDStore(t, filepath.Join(t.TempDir(), "subsonic_ids.db"))
+	ref := SubsonicRef{ID: "1", Name: "A"}
+	s.Put("tr:qq:1", ref)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	s.Put("tr:qq:1", ref)
+	if n := len(s.pending); n != 0 {
+		t.Fatalf("pending after unchanged put = %d, want 0", n)
+	}
+	ref.Name = "B"
+	s.Put("tr:qq:1", ref)
+	if n := len(s.pending); n != 1 {
+		t.Fatalf("pending after changed put = %d, want 1", n)
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/metrics"
+)
+
+// 上游能力名称，用于出站限流等按能力区分的统一处理
+const (
+	CapSearch             = "search"
+	CapAlbumSearch        = "album_search"
+	CapPlaylistSearch     = "playlist_search"
+	CapDownloadURL        = "download_url"
+	CapLyric              = "lyric"
+	CapParse              = "parse"
+	CapParsePlaylist      = "parse_playlist"
+	CapParseAlbum         = "parse_album"
+	CapPlaylistDetail     = "playlist_detail"
+	CapAlbumDetail        = "album_detail"
+	CapRecommend          = "recommend"
+	CapPlaylistCategories = "playlist_categories"
+	CapCategoryPlaylists  = "category_playlists"
+	CapUserPlaylists      = "user_playlists"
+	CapQRLoginCreate      = "qr_create"
+	CapQRLoginCheck       = "qr_check"
+)
+
+// upstreamCall 描述一次上游调用所属的请求、平台、能力以及所用账号的 Cookie
+type upstreamCall struct {
+	ctx        context.Context
+	source     string
+	capability string
+	cookie     string
+}
+
+type diagnosticContextKey struct{}
+
+// withDiagnostic 标记诊断调用(Cookie 校验、合成探测)：只尝试一次，不经熔断与出站限流，
+// 结果也不计入熔断与账号池，避免诊断本身改变被诊断对象的状态
+func withDiagnostic(ctx context.Context) context.Context {
+	return context.WithValue(ctx, diagnosticContextKey{}, true)
+}
+
+func isDiagnostic(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	diagnostic, _ := ctx.Value(diagnosticContextKey{}).(bool)
+	return diagnostic
+}
+
+// callUpstream 执行一次上游调用，所有工厂函数返回的函数都经由此处。
+// 瞬时错误按能力的重试策略退避重试，每次尝试都单独经过熔断与出站限流；诊断调用只尝试一次
+func callUpstream(call upstreamCall, fn func() error) error {
+	policy := retryPolicy(call.capability)
+	if isDiagnostic(call.ctx) {
+		policy.MaxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		called, err := call.attempt(attempt, fn)
+		if err == nil || !called || attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+		metrics.UpstreamRetries.WithLabelValues(call.source, call.capability).Inc()
+		if !sleepContext(call.ctx, retryDelay(policy, attempt)) {
+			return err
+		}
+	}
+}
+
+// attempt 执行一次尝试，called 表示是否实际访问了上游；被熔断或出站限流拒绝时为 false
+func (call upstreamCall) attempt(n int, fn func() error) (called bool, err error) {
+	diagnostic := isDiagnostic(call.ctx)
+	var trial bool
+	if !diagnostic {
+		trial, err = CB.acquire(call.source)
+		if err == nil {
+			if err = outboundLimiter.wait(call.ctx, call.source); err != nil {
+				CB.release(call.source, trial)
+			}
+		}
+	}
+	if err != nil {
+		metrics.UpstreamRequests.WithLabelValues(call.source, call.capability, UpstreamResult(err)).Inc()
+		call.log(n, 0, err)
+		return false, err
+	}
+	start := time.Now()
+	err = fn()
+	elapsed := time.Since(start)
+	if !diagnostic {
+		CB.record(call.source, trial, err)
+	}
+	metrics.UpstreamDuration.WithLabelValues(call.source, call.capability).Observe(elapsed.Seconds())
+	metrics.UpstreamRequests.WithLabelValues(call.source, call.capability, UpstreamResult(err)).Inc()
+	call.log(n, elapsed, err)
+	if call.cookie != "" && !diagnostic {
+		CM.report(call.source, call.cookie, err)
+	}
+	return true, err
+}
+
+// sleepContext 等待 d，ctx 先被取消时提前返回 false；ctx 为 nil 时不可取消
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx == nil || ctx.Err() == nil
+	}
+	if ctx == nil {
+		time.Sleep(d)
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// log 以 debug 级别记录一次上游调用，日志带上所属请求的 ID；Cookie 只记录是否携带，不输出内容
+func (call upstreamCall) log(attempt int, elapsed time.Duration, err error) {
+	ctx := call.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("source", call.source),
+		slog.String("capability", call.capability),
+		slog.Int("attempt", attempt),
+		slog.Int64("duration_ms", elapsed.Milliseconds()),
+		slog.String("result", UpstreamResult(err)),
+		slog.Bool("authenticated", call.cookie != ""),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.LogAttrs(ctx, slog.LevelDebug, "upstream call", attrs...)
+}
+
+// UpstreamResult 将调用结果映射为指标中的 result 标签，也用于向调用方报告各平台的结果
+func UpstreamResult(err error) string {
+	if err == nil {
+		return metrics.ResultOK
+	}
+	switch classifyError(err) {
+	case ErrNotFound:
+		return metrics.ResultNotFound
+	case ErrAuthRequired:
+		return metrics.ResultAuthRequired
+	case ErrPaidContent:
+		return metrics.ResultPaidContent
+	case ErrRateLimited:
+		return metrics.ResultRateLimited
+	case ErrTimeout:
+		return metrics.ResultTimeout
+	case ErrCircuitOpen:
+		return metrics.ResultCircuitOpen
+	default:
+		return metrics.ResultUpstream
+	}
+}
+
+// 以下辅助函数按参数个数包装工厂函数，fn 为 nil 时原样返回 nil
+
+func wrap0[R any](call upstreamCall, fn func() (R, error)) func() (R, error) {
+	if fn == nil {
+		return nil
+	}
+	return func() (R, error) {
+		var r R
+		err := callUpstream(call, func() (err error) {
+			r, err = fn()
+			return err
+		})
+		return r, err
+	}
+}
+
+func wrap1[A, R any](call upstreamCall, fn func(A) (R, error)) func(A) (R, error) {
+	if fn == nil {
+		return nil
+	}
+	return func(a A) (R, error) {
+		var r R
+		err := callUpstream(call, func() (err error) {
+			r, err = fn(a)
+			return err
+		})
+		return r, err
+	}
+}
+
+func wrap2[A, B, R any](call upstreamCall, fn func(A, B) (R, error)) func(A, B) (R, error) {
+	if fn == nil {
+		return nil
+	}
+	return func(a A, b B) (R, error) {
+		var r R
+		err := callUpstream(call, func() (err error) {
+			r, err = fn(a, b)
+			return err
+		})
+		return r, err
+	}
+}
+
+func wrap3[A, B, C, R any](call upstreamCall, fn func(A, B, C) (R, error)) func(A, B, C) (R, error) {
+	if fn == nil {
+		return nil
+	}
+	return func(a A, b B, c C) (R, error) {
+		var r R
+		err := callUpstream(call, func() (err error) {
+			r, err = fn(a, b, c)
+			return err
+		})
+		return r, err
+	}
+}
+
+func wrap1x2[A, R1, R2 any](call upstreamCall, fn func(A) (R1, R2, error)) func(A) (R1, R2, error) {
+	if fn == nil {
+		return nil
+	}
+	return func(a A) (R1, R2, error) {
+		var r1 R1
+		var r2 R2
+		err := callUpstream(call, func() (err error) {
+			r1, r2, err = fn(a)
+			return err
+		})
+		return r1, r2, err
+	}
+}
+
+// 以下辅助函数在每次调用时才选取 Cookie 并创建平台客户端，再按参数个数经 callUpstream 执行。
+// 工厂函数本身只判断能力是否存在，不会推进账号池的轮换；factory 返回 nil 时原样返回 nil
+
+func pooled0[F ~func() (R, error), R any](call upstreamCall, factory func(source, cookie string) F) F {
+	if factory(call.source, "") == nil {
+		return nil
+	}
+	return func() (R, error) {
+		call := call.withPooledCookie()
+		return wrap0(call, factory(call.source, call.cookie))()
+	}
+}
+
+func pooled1[F ~func(A) (R, error), A, R any](call upstreamCall, factory func(source, cookie string) F) F {
+	if factory(call.source, "") == nil {
+		return nil
+	}
+	return func(a A) (R, error) {
+		call := call.withPooledCookie()
+		return wrap1(call, factory(call.source, call.cookie))(a)
+	}
+}
+
+func pooled2[F ~func(A, B) (R, error), A, B, R any](call upstreamCall, factory func(source, cookie string) F) F {
+	if factory(call.source, "") == nil {
+		return nil
+	}
+	return func(a A, b B) (R, error) {
+		call := call.withPooledCookie()
+		return wrap2(call, factory(call.source, call.cookie))(a, b)
+	}
+}
+
+func pooled3[F ~func(A, B, C) (R, error), A, B, C, R any](call upstreamCall, factory func(source, cookie string) F) F {
+	if factory(call.source, "") == nil {
+		return nil
+	}
+	return func(a A, b B, c C) (R, error) {
+		call := call.withPooledCookie()
+		return wrap3(call, factory(call.source, call.cookie))(a, b, c)
+	}
+}
+
+func pooled1x2[F ~func(A) (R1, R2, error), A, R1, R2 any](call upstreamCall, factory func(source, cookie string) F) F {
+	if factory(call.source, "") == nil {
+		return nil
+	}
+	return func(a A) (R1, R2, error) {
+		call := call.withPooledCookie()
+		return wrap1x2(call, factory(call.source, call.cookie))(a)
+	}
+}
+
+// withPooledCookie 返回填入本次调用所用 Cookie 的副本：请求自带的优先，否则从账号池选取
+func (call upstreamCall) withPooledCookie() upstreamCall {
+	call.cookie = CookieFor(call.ctx, call.source)
+	return call
+}
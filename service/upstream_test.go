@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/guohuiyuan/go-music-api/config"
+)
+
+func TestDiagnosticCallBypassesBreakerAndRetry(t *testing.T) {
+	old := CB
+	CB = newTestBreakers(t)
+	t.Cleanup(func() { CB = old })
+	oldRetry := config.C.Retry
+	t.Cleanup(func() { config.C.Retry = oldRetry })
+	config.C.Retry.Default = config.RetryPolicy{MaxAttempts: 3}
+
+	timeout := NewSourceError("qq", ErrTimeout, nil)
+	CB.record("qq", false, timeout)
+	CB.record("qq", false, timeout)
+	if !CB.Open("qq") {
+		t.Fatal("circuit not open")
+	}
+
+	call := upstreamCall{ctx: withDiagnostic(context.Background()), source: "qq", capability: CapSearch}
+	calls := 0
+	err := callUpstream(call, func() error {
+		calls++
+		return timeout
+	})
+	if err != timeout || calls != 1 {
+		t.Fatalf("diagnostic call err = %v after %d calls, want the upstream error after 1 call", err, calls)
+	}
+	if state := CB.State("qq"); state.State != CircuitOpen || state.Failures != 2 {
+		t.Fatalf("breaker state = %+v, want untouched open circuit with 2 failures", state)
+	}
+
+	call.ctx = context.Background()
+	calls = 0
+	if err := callUpstream(call, func() error { calls++; return nil }); !errors.Is(err, ErrCircuitOpen) || calls != 0 {
+		t.Fatalf("regular call err = %v after %d calls, want circuit open", err, calls)
+	}
+}
@@ -0,0 +1,461 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guohuiyuan/go-music-api/config"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserFile 用户、令牌与个人 Cookie 保险箱的嵌入式数据库(bbolt)，用户记录与 cookies.json 使用同一加密密钥
+const UserFile = "users.db"
+
+// UserTokenPrefix 用户 API 令牌的前缀，便于与配置文件中的 API Key 区分
+const UserTokenPrefix = "mu_"
+
+var (
+	ErrUserExists         = errors.New("username already exists")
+	ErrInvalidUser        = errors.New("invalid username or password format")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+
+	errUserStoreClosed = errors.New("user store is not loaded")
+
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+)
+
+var (
+	usersBucket  = []byte("users")  // 小写用户名 -> 用户记录，配置了密钥时加密保存
+	tokensBucket = []byte("tokens") // 令牌摘要 -> 令牌记录
+)
+
+const minPasswordLength = 8
+
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// User 本地用户，Cookies 为该用户的个人 Cookie 保险箱
+type User struct {
+	Username     string            `json:"username"`
+	PasswordHash string            `json:"password_hash"`
+	CreatedAt    time.Time         `json:"created_at"`
+	Cookies      map[string]string `json:"cookies,omitempty"`
+}
+
+// UserToken 用户的 API 令牌记录，以令牌的 SHA-256 摘要为键保存，不含令牌本身
+type UserToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used,omitzero"`
+}
+
+// UserTokenInfo 返回给调用方的令牌信息，不含令牌本身
+type UserTokenInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used,omitzero"`
+}
+
+// UserInfo 返回给调用方的用户信息，Cookie 已脱敏
+type UserInfo struct {
+	Username  string            `json:"username"`
+	CreatedAt time.Time         `json:"created_at"`
+	Tokens    []UserTokenInfo   `json:"tokens"`
+	Cookies   map[string]string `json:"cookies"`
+}
+
+// userTouchInterval 令牌最近使用时间的记录粒度，间隔内的重复使用不再写库
+const userTouchInterval = time.Minute
+
+// UserStore 管理本地用户，数据保存在嵌入式数据库中，每次变更在独立事务中提交，无需外部数据库
+type UserStore struct {
+	db  *bolt.DB
+	key []byte
+
+	touchMu sync.Mutex
+	touched map[string]time.Time // 令牌摘要 -> 最近一次写库的使用时间
+}
+
+var US = &UserStore{touched: make(map[string]time.Time)}
+
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load 打开用户数据库，需在处理请求前调用。配置了加密密钥时，明文保存的用户记录会立即加密重写
+func (s *UserStore) Load() error {
+	key, err := config.C.Cookies.Key()
+	if err != nil {
+		return err
+	}
+	if err := s.open(UserFile, key); err != nil {
+		return fmt.Errorf("load %s: %w", UserFile, err)
+	}
+	return nil
+}
+
+func (s *UserStore) open(path string, key []byte) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(tokensBucket); err != nil {
+			return err
+		}
+		users, err := tx.CreateBucketIfNotExists(usersBucket)
+		if err != nil {
+			return err
+		}
+		// 逐条校验能否解密，口令不匹配时拒绝启动而不是覆盖原数据
+		var upgrade []*User
+		err = users.ForEach(func(_, v []byte) error {
+			plain, current, err := openCookieFile(v, key)
+			if err != nil {
+				return err
+			}
+			if key != nil && !current {
+				var u User
+				if err := json.Unmarshal(plain, &u); err != nil {
+					return err
+				}
+				upgrade = append(upgrade, &u)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, u := range upgrade {
+			if err := putUser(users, u, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	s.db, s.key = db, key
+	return nil
+}
+
+// Close 关闭用户数据库
+func (s *UserStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *UserStore) view(fn func(tx *bolt.Tx) error) error {
+	if s.db == nil {
+		return errUserStoreClosed
+	}
+	return s.db.View(fn)
+}
+
+func (s *UserStore) update(fn func(tx *bolt.Tx) error) error {
+	if s.db == nil {
+		return errUserStoreClosed
+	}
+	return s.db.Update(fn)
+}
+
+func (s *UserStore) getUser(tx *bolt.Tx, username string) (*User, error) {
+	v := tx.Bucket(usersBucket).Get([]byte(strings.ToLower(strings.TrimSpace(username))))
+	if v == nil {
+		return nil, nil
+	}
+	plain, _, err := openCookieFile(v, s.key)
+	if err != nil {
+		return nil, err
+	}
+	var u User
+	if err := json.Unmarshal(plain, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func putUser(b *bolt.Bucket, u *User, key []byte) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if data, err = sealCookieFile(data, key); err != nil {
+		return err
+	}
+	return b.Put([]byte(strings.ToLower(u.Username)), data)
+}
+
+func getToken(tx *bolt.Tx, hash string) (*UserToken, error) {
+	v := tx.Bucket(tokensBucket).Get([]byte(hash))
+	if v == nil {
+		return nil, nil
+	}
+	var t UserToken
+	if err := json.Unmarshal(v, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func putToken(tx *bolt.Tx, hash string, t *UserToken) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(tokensBucket).Put([]byte(hash), data)
+}
+
+// userNotFound 用户不存在时返回的错误
+func userNotFound() error {
+	return NewSourceError("", ErrNotFound, errors.New("user not found"))
+}
+
+// Register 创建用户，用户名不区分大小写
+func (s *UserStore) Register(username, password string) (UserInfo, error) {
+	username = strings.TrimSpace(username)
+	if !usernamePattern.MatchString(username) {
+		return UserInfo{}, NewSourceError("", ErrInvalidUser, errors.New("username must be 3-32 characters of letters, digits, '_', '.' or '-'"))
+	}
+	if len(password) < minPasswordLength {
+		return UserInfo{}, NewSourceError("", ErrInvalidUser, fmt.Errorf("password must be at least %d characters", minPasswordLength))
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return UserInfo{}, err
+	}
+
+	u := &User{Username: username, PasswordHash: string(hash), CreatedAt: time.Now()}
+	err = s.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(usersBucket).Get([]byte(strings.ToLower(username))) != nil {
+			return ErrUserExists
+		}
+		return putUser(tx.Bucket(usersBucket), u, s.key)
+	})
+	if err != nil {
+		return UserInfo{}, err
+	}
+	return u.info(nil), nil
+}
+
+// Login 校验密码并签发新的 API 令牌，令牌明文只在此时返回一次
+func (s *UserStore) Login(username, password, tokenName string) (string, UserTokenInfo, error) {
+	var u *User
+	err := s.view(func(tx *bolt.Tx) (err error) {
+		u, err = s.getUser(tx, username)
+		return err
+	})
+	if err != nil {
+		return "", UserTokenInfo{}, err
+	}
+	if u == nil {
+		// 用户不存在时同样计算一次哈希，避免通过响应时间枚举用户名
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return "", UserTokenInfo{}, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return "", UserTokenInfo{}, ErrInvalidCredentials
+	}
+	return s.CreateToken(u.Username, tokenName)
+}
+
+// CreateToken 为用户签发新的 API 令牌
+func (s *UserStore) CreateToken(username, name string) (string, UserTokenInfo, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", UserTokenInfo{}, err
+	}
+	token := UserTokenPrefix + hex.EncodeToString(raw)
+
+	var t *UserToken
+	err := s.update(func(tx *bolt.Tx) error {
+		u, err := s.getUser(tx, username)
+		if err != nil {
+			return err
+		}
+		if u == nil {
+			return userNotFound()
+		}
+		t = &UserToken{ID: newCookieAccountID(), Name: strings.TrimSpace(name), Username: u.Username, CreatedAt: time.Now()}
+		return putToken(tx, hashUserToken(token), t)
+	})
+	if err != nil {
+		return "", UserTokenInfo{}, err
+	}
+	return token, t.info(), nil
+}
+
+// RevokeToken 吊销用户的 API 令牌
+func (s *UserStore) RevokeToken(username, id string) error {
+	var revoked string
+	err := s.update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(tokensBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var t UserToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			if t.ID == id && strings.EqualFold(t.Username, username) {
+				revoked = string(k)
+				return c.Delete()
+			}
+		}
+		return NewSourceError("", ErrNotFound, fmt.Errorf("token %s not found", id))
+	})
+	if err == nil {
+		s.touchMu.Lock()
+		delete(s.touched, revoked)
+		s.touchMu.Unlock()
+	}
+	return err
+}
+
+// LookupToken 根据 API 令牌返回用户名。令牌的最近使用时间按 userTouchInterval 的粒度记录，
+// 查找本身只使用只读事务，不会让并发请求互相等待
+func (s *UserStore) LookupToken(token string) (string, bool) {
+	if !strings.HasPrefix(token, UserTokenPrefix) {
+		return "", false
+	}
+	hash := hashUserToken(token)
+	var t *UserToken
+	err := s.view(func(tx *bolt.Tx) (err error) {
+		t, err = getToken(tx, hash)
+		return err
+	})
+	if err != nil || t == nil {
+		return "", false
+	}
+	s.touch(hash, t.LastUsed)
+	return t.Username, true
+}
+
+// touch 在距上次记录超过 userTouchInterval 时写入令牌的最近使用时间，同一令牌在间隔内只有一个请求负责写入
+func (s *UserStore) touch(hash string, lastUsed time.Time) {
+	now := time.Now()
+	if now.Sub(lastUsed) < userTouchInterval {
+		return
+	}
+	s.touchMu.Lock()
+	if now.Sub(s.touched[hash]) < userTouchInterval {
+		s.touchMu.Unlock()
+		return
+	}
+	s.touched[hash] = now
+	s.touchMu.Unlock()
+
+	err := s.update(func(tx *bolt.Tx) error {
+		t, err := getToken(tx, hash)
+		if err != nil || t == nil {
+			return err
+		}
+		t.LastUsed = now
+		return putToken(tx, hash, t)
+	})
+	if err != nil {
+		slog.Warn("record token usage failed", "error", err.Error())
+	}
+}
+
+// Info 返回用户信息
+func (s *UserStore) Info(username string) (UserInfo, bool) {
+	var u *User
+	var tokens []*UserToken
+	err := s.view(func(tx *bolt.Tx) (err error) {
+		if u, err = s.getUser(tx, username); err != nil || u == nil {
+			return err
+		}
+		return tx.Bucket(tokensBucket).ForEach(func(_, v []byte) error {
+			var t UserToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			if strings.EqualFold(t.Username, u.Username) {
+				tokens = append(tokens, &t)
+			}
+			return nil
+		})
+	})
+	if err != nil || u == nil {
+		return UserInfo{}, false
+	}
+	return u.info(tokens), true
+}
+
+// VaultCookies 返回用户保险箱中的平台 Cookie
+func (s *UserStore) VaultCookies(username string) Cookies {
+	var u *User
+	err := s.view(func(tx *bolt.Tx) (err error) {
+		u, err = s.getUser(tx, username)
+		return err
+	})
+	if err != nil || u == nil || len(u.Cookies) == 0 {
+		return nil
+	}
+	return Cookies(u.Cookies)
+}
+
+// SetVaultCookies 更新用户保险箱中的平台 Cookie，值为空时删除
+func (s *UserStore) SetVaultCookies(username string, cookies map[string]string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		u, err := s.getUser(tx, username)
+		if err != nil {
+			return err
+		}
+		if u == nil {
+			return userNotFound()
+		}
+		if u.Cookies == nil {
+			u.Cookies = make(map[string]string)
+		}
+		for source, cookie := range cookies {
+			source, cookie = strings.TrimSpace(source), strings.TrimSpace(cookie)
+			if source == "" {
+				continue
+			}
+			if cookie == "" {
+				delete(u.Cookies, source)
+				continue
+			}
+			u.Cookies[source] = cookie
+		}
+		return putUser(tx.Bucket(usersBucket), u, s.key)
+	})
+}
+
+// info 生成用户信息，令牌按签发时间排序
+func (u *User) info(tokens []*UserToken) UserInfo {
+	info := UserInfo{Username: u.Username, CreatedAt: u.CreatedAt, Tokens: []UserTokenInfo{}, Cookies: map[string]string{}}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	for _, t := range tokens {
+		info.Tokens = append(info.Tokens, t.info())
+	}
+	for source, cookie := range u.Cookies {
+		info.Cookies[source] = MaskCookie(cookie)
+	}
+	return info
+}
+
+func (t *UserToken) info() UserTokenInfo {
+	return UserTokenInfo{ID: t.ID, Name: t.Name, CreatedAt: t.CreatedAt, LastUsed: t.LastUsed}
+}
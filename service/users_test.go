@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestUserStore(t *testing.T, path string, key []byte) *UserStore {
+	t.Helper()
+	s := &UserStore{touched: make(map[string]time.Time)}
+	if err := s.open(path, key); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestUserStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	key := []byte("vault secret")
+	s := openTestUserStore(t, path, key)
+
+	if _, err := s.Register("Alice", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register("alice", "password123"); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("duplicate register err = %v", err)
+	}
+	if _, err := s.Register("al", "password123"); !errors.Is(err, ErrInvalidUser) {
+		t.Fatalf("short username err = %v", err)
+	}
+	token, info, err := s.Login("alice", "password123", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetVaultCookies("alice", map[string]string{"qq": "uin=1"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s = openTestUserStore(t, path, key)
+	if name, ok := s.LookupToken(token); !ok || name != "Alice" {
+		t.Fatalf("LookupToken = %q, %v", name, ok)
+	}
+	if got := s.VaultCookies("ALICE")["qq"]; got != "uin=1" {
+		t.Fatalf("vault cookie = %q", got)
+	}
+	if err := s.RevokeToken("alice", info.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.LookupToken(token); ok {
+		t.Fatal("revoked token still valid")
+	}
+	s.Close()
+
+	if err := (&UserStore{}).open(path, []byte("wrong")); err == nil {
+		t.Fatal("expected error for wrong key")
+	}
+}
+
+func TestUserStoreTouchGranularity(t *testing.T) {
+	s := openTestUserStore(t, filepath.Join(t.TempDir(), "users.db"), nil)
+	if _, err := s.Register("bob", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.CreateToken("bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.LookupToken(token)
+	first, _ := s.Info("bob")
+	if first.Tokens[0].LastUsed.IsZero() {
+		t.Fatal("first use not recorded")
+	}
+	s.LookupToken(token)
+	second, _ := s.Info("bob")
+	if !second.Tokens[0].LastUsed.Equal(first.Tokens[0].LastUsed) {
+		t.Fatal("use within the interval was written again")
+	}
+}